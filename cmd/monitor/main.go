@@ -1,22 +1,287 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/notifier"
+	"all-unifi-monitor/internal/server"
 	"all-unifi-monitor/internal/store"
+	"all-unifi-monitor/internal/version"
 	"all-unifi-monitor/pkg/logger"
 )
 
 func main() {
+	var (
+		configPath   = flag.String("config", "", "path to the config file (default "+config.DefaultPath+")")
+		webhookURL   = flag.String("webhook-url", "", "Discord webhook URL")
+		pollInterval = flag.Duration("poll-interval", 0, "time to wait between sweeps, e.g. 30s")
+		productsFile = flag.String("products-file", "", "file used to store known products")
+		dryRun       = flag.Bool("dry-run", false, "log notifications instead of sending them")
+		stateless    = flag.Bool("stateless", false, "keep known products in memory only, never reading or writing the products file")
+		once         = flag.Bool("once", false, "run a single sweep and exit")
+		compact      = flag.Bool("compact", false, "rewrite the products file without superseded records and exit")
+		testNotify   = flag.Bool("test-notify", false, "send a sample product through every configured notifier and exit")
+		export       = flag.String("export", "", "print the price history of the given product ID and exit")
+		exportFormat = flag.String("export-format", "csv", "format used by --export: csv or json")
+		showVersion  = flag.Bool("version", false, "print version information and exit")
+		replayDir    = flag.String("replay", "", "read category listings from captured <category>.json files in this directory instead of the store")
+	)
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Println("unifi-monitor", version.String())
+		return
+	}
+
 	logger.Info().Msg("Initializing...")
 
-	cfg, err := config.Load()
+	// Precedence: flags > environment > config file > defaults
+	loadConfig := func() (*config.Config, error) {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if *webhookURL != "" {
+			cfg.DiscordWebhookURL = *webhookURL
+		}
+		if *pollInterval != 0 {
+			cfg.PollInterval = *pollInterval
+		}
+		if *productsFile != "" {
+			cfg.ProductsFile = *productsFile
+		}
+		if *dryRun {
+			cfg.DryRun = true
+		}
+		if *stateless {
+			cfg.Stateless = true
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig()
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Failed to load configuration")
 	}
 
-	unifiStore := store.New(cfg)
+	if err := logger.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to configure logger")
+	}
+
+	logger.Info().
+		Str("version", version.Version).
+		Str("commit", version.Commit).
+		Str("built", version.Date).
+		Str("go", version.GoVersion()).
+		Msg("Unifi Store Monitor")
+
+	if cfg.DryRun {
+		logger.Warning().Msg("Dry run enabled, notifications will only be logged")
+	}
+
+	if cfg.Stateless {
+		logger.Warning().Msg("Stateless mode enabled, known products are kept in memory only and seeded from the first sweep")
+	}
+
+	if cfg.UpdateCheck {
+		go checkForUpdate()
+	}
+
+	if *testNotify {
+		if failed := sendTestNotifications(cfg); failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Only reads the price history, so it works while a monitor is running
+	if *export != "" {
+		if err := exportPriceHistory(cfg.ProductsFile, *export, *exportFormat); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to export price history")
+		}
+		return
+	}
+
+	unifiStore, err := store.New(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create store")
+	}
+
+	if *replayDir != "" {
+		logger.Warning().Str("dir", *replayDir).Msg("Replay mode, category listings are read from captured responses")
+		unifiStore.SetReplayDir(*replayDir)
+	}
+
+	if *compact {
+		if err := unifiStore.Compact(); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to compact products file")
+		}
+		logger.Info().Msg("Products file compacted")
+		return
+	}
+
+	if *once {
+		if cfg.Role == config.RoleStandby {
+			logger.Fatal().Msg("--once can't run as a standby")
+		}
+		if cfg.NotifyMode == config.NotifyModeDigest {
+			logger.Info().Msg("Digest mode with --once, the digest is sent when the sweep ends")
+		}
+		if quietHours, _ := cfg.QuietHours(); quietHours != nil {
+			logger.Warning().Msg("Quiet hours have no effect with --once, held alerts are sent when the sweep ends")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		runErr := unifiStore.RunOnce(ctx)
+		unifiStore.FlushAlerts()
+		if err := unifiStore.Flush(); err != nil {
+			logger.Error().Err(err).Msg("Failed to save known products")
+		}
+		if runErr != nil {
+			logger.Fatal().Err(runErr).Msg("Sweep failed")
+		}
+		logger.Info().Msg("Sweep complete")
+		return
+	}
+
+	if cfg.ListenAddr != "" {
+		httpServer := server.New(cfg.ListenAddr, unifiStore)
+		httpServer.SetAdminToken(cfg.AdminToken)
+		httpServer.Start()
+	}
+
+	// Reload the configuration on SIGHUP, keeping the known products
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			logger.Info().Msg("Received SIGHUP, reloading configuration")
+
+			cfg, err := loadConfig()
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to reload configuration, keeping the current one")
+				continue
+			}
+			if err := logger.Configure(cfg.LogLevel, cfg.LogFormat); err != nil {
+				logger.Error().Err(err).Msg("Failed to reconfigure logger")
+			}
+			if err := unifiStore.Reload(cfg); err != nil {
+				logger.Error().Err(err).Msg("Failed to reload configuration, keeping the current one")
+			}
+		}
+	}()
+
 	go unifiStore.Start()
 
 	// Keep the main thread alive
 	select {}
 }
+
+// exportPriceHistory writes the price history of a product to stdout.
+func exportPriceHistory(productsFile, productID, format string) error {
+	points, err := store.ReadPriceHistory(productsFile, productID)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		logger.Warning().Str("id", productID).Msg("No price history recorded for product")
+	}
+
+	switch format {
+	case "csv":
+		return store.WritePriceHistoryCSV(os.Stdout, points)
+	case "json":
+		if points == nil {
+			points = []store.PricePoint{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(points)
+	default:
+		return fmt.Errorf("unknown export format %q, must be csv or json", format)
+	}
+}
+
+// sendTestNotifications sends a sample product through every configured
+// notifier, bypassing digest mode, and returns how many of them failed.
+func sendTestNotifications(cfg *config.Config) int {
+	notifiers, err := notifier.Backends(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create notifiers")
+	}
+	if len(notifiers) == 0 {
+		logger.Fatal().Msg("No notifiers configured")
+	}
+
+	product := models.Product{
+		ID:               "test-notification",
+		Title:            "Test Notification",
+		ShortDescription: "This is a test notification from Unifi Store Monitor.",
+		Slug:             "test-notification",
+		// Telegram and Pushover need a real image to attach
+		Thumbnail: models.Thumbnail{URL: "https://tse3.mm.bing.net/th?id=OIP.RadjPrUUrLwqfVTEI5YqmwHaIV&pid=Api&P=0&w=300&h=300"},
+	}
+	variant := models.Variant{ID: "test-variant"}
+	variant.DisplayPrice.Amount = 9999
+	variant.DisplayPrice.Currency = "USD"
+	product.Variants = []models.Variant{variant}
+
+	failed := 0
+	for _, n := range notifiers {
+		// Test notifications ignore notifier_events
+		if s, ok := n.(*notifier.Subscribed); ok {
+			n = s.Unwrap()
+		}
+		if r, ok := n.(*notifier.Revocable); ok {
+			n = r.Unwrap()
+		}
+		if err := n.SendProduct(product); err != nil {
+			logger.Error().Err(err).Str("notifier", n.Name()).Msg("Test notification failed")
+			failed++
+			continue
+		}
+		logger.Info().Str("notifier", n.Name()).Msg("Test notification sent")
+	}
+
+	logger.Info().Msgf("%d of %d notifiers succeeded", len(notifiers)-failed, len(notifiers))
+	return failed
+}
+
+// checkForUpdate logs a warning when a newer release than the running build
+// has been published.
+func checkForUpdate() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	update, err := version.CheckForUpdate(ctx)
+	if err != nil {
+		logger.Warning().Err(err).Msg("Failed to check for updates")
+		return
+	}
+
+	if update.Newer {
+		logger.Warning().
+			Str("current", version.Version).
+			Str("latest", update.Latest).
+			Str("url", update.URL).
+			Msg("A newer version is available")
+		return
+	}
+	logger.Info().Str("current", version.Version).Str("latest", update.Latest).Msg("Checked for updates")
+}
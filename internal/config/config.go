@@ -1,40 +1,479 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"gopkg.in/yaml.v2"
+	"all-unifi-monitor/internal/message"
 )
 
 type Config struct {
-	DiscordWebhookURL string `yaml:"discord_webhook_url"`
-	SaveBatchSize     int    `yaml:"save_batch_size"`
-	HomeURL           string `yaml:"home_url"`
-	ProductsFile      string `yaml:"products_file"`
+	DiscordWebhookURL      string              `yaml:"discord_webhook_url"`
+	SaveBatchSize          int                 `yaml:"save_batch_size"`
+	SaveInterval           time.Duration       `yaml:"save_interval"`
+	CompactOnLoad          bool                `yaml:"compact_on_load"`
+	HomeURL                string              `yaml:"home_url"`
+	ProductsFile           string              `yaml:"products_file"`
+	RemovalThreshold       int                 `yaml:"removal_threshold"`
+	DropRemoved            bool                `yaml:"drop_removed"`
+	EmptySweepThreshold    int                 `yaml:"empty_sweep_threshold"`
+	MaxAlertsPerSweep      int                 `yaml:"max_alerts_per_sweep"`
+	PruneAfter             time.Duration       `yaml:"prune_after"`
+	IncludeCategories      []string            `yaml:"include_categories"`
+	ExcludeCategories      []string            `yaml:"exclude_categories"`
+	MinPrice               float64             `yaml:"min_price"`
+	MaxPrice               float64             `yaml:"max_price"`
+	TitleIncludePatterns   []string            `yaml:"title_include_patterns"`
+	TitleExcludePatterns   []string            `yaml:"title_exclude_patterns"`
+	TelegramBotToken       string              `yaml:"telegram_bot_token"`
+	TelegramChatID         string              `yaml:"telegram_chat_id"`
+	Discord                DiscordConfig       `yaml:"discord"`
+	MaxRetries             int                 `yaml:"max_retries"`
+	MaxBackoff             time.Duration       `yaml:"max_backoff"`
+	MaxElapsedTime         time.Duration       `yaml:"max_elapsed_time"`
+	BreakerThreshold       int                 `yaml:"breaker_threshold"`
+	BreakerCooldown        time.Duration       `yaml:"breaker_cooldown"`
+	MaxRequestsPerSecond   float64             `yaml:"max_requests_per_second"`
+	BatchAlerts            bool                `yaml:"batch_alerts"`
+	EnrichAlerts           bool                `yaml:"enrich_alerts"`
+	AlertCooldown          time.Duration       `yaml:"alert_cooldown"`
+	Watchlist              []string            `yaml:"watchlist"`
+	WatchInterval          time.Duration       `yaml:"watch_interval"`
+	DryRun                 bool                `yaml:"dry_run"`
+	Stateless              bool                `yaml:"stateless"`
+	Role                   string              `yaml:"role"`
+	StandbyRefreshInterval time.Duration       `yaml:"standby_refresh_interval"`
+	UpdateCheck            bool                `yaml:"update_check"`
+	PollInterval           time.Duration       `yaml:"poll_interval"`
+	BuildIDTTL             time.Duration       `yaml:"build_id_ttl"`
+	PollJitter             float64             `yaml:"poll_jitter"`
+	LogLevel               string              `yaml:"log_level"`
+	LogFormat              string              `yaml:"log_format"`
+	NtfyServer             string              `yaml:"ntfy_server"`
+	NtfyTopic              string              `yaml:"ntfy_topic"`
+	MatrixHomeserver       string              `yaml:"matrix_homeserver"`
+	MatrixAccessToken      string              `yaml:"matrix_access_token"`
+	MatrixRoomID           string              `yaml:"matrix_room_id"`
+	NATSURL                string              `yaml:"nats_url"`
+	NATSSubject            string              `yaml:"nats_subject"`
+	SMTPHost               string              `yaml:"smtp_host"`
+	SMTPPort               int                 `yaml:"smtp_port"`
+	SMTPUser               string              `yaml:"smtp_user"`
+	SMTPPass               string              `yaml:"smtp_pass"`
+	EmailFrom              string              `yaml:"email_from"`
+	EmailTo                []string            `yaml:"email_to"`
+	Proxies                []string            `yaml:"proxies"`
+	ProxyCooldown          time.Duration       `yaml:"proxy_cooldown"`
+	SOCKS5Proxy            string              `yaml:"socks5_proxy"`
+	RegionProxies          map[string]string   `yaml:"region_proxies"`
+	MinDiscountPercent     float64             `yaml:"min_discount_percent"`
+	CategoryWebhooks       map[string]string   `yaml:"category_webhooks"`
+	WatchMetadataChanges   bool                `yaml:"watch_metadata_changes"`
+	WatchAvailability      bool                `yaml:"watch_availability"`
+	UpcomingZeroPrice      bool                `yaml:"upcoming_zero_price"`
+	UpcomingTitleMarkers   []string            `yaml:"upcoming_title_markers"`
+	PushoverAppToken       string              `yaml:"pushover_app_token"`
+	PushoverUserKey        string              `yaml:"pushover_user_key"`
+	VerifyWebhooks         bool                `yaml:"verify_webhooks"`
+	ListenAddr             string              `yaml:"listen_addr"`
+	AdminToken             string              `yaml:"admin_token"`
+	EventHistorySize       int                 `yaml:"event_history_size"`
+	HTTPTimeout            time.Duration       `yaml:"http_timeout"`
+	NotifyMaxAttempts      int                 `yaml:"notify_max_attempts"`
+	NotifyRetryBackoff     time.Duration       `yaml:"notify_retry_backoff"`
+	UserAgent              string              `yaml:"user_agent"`
+	AcceptLanguage         string              `yaml:"accept_language"`
+	ExtraHeaders           map[string]string   `yaml:"extra_headers"`
+	BypassCache            string              `yaml:"bypass_cache"`
+	NotifyMode             string              `yaml:"notify_mode"`
+	DigestInterval         time.Duration       `yaml:"digest_interval"`
+	QuietHoursStart        string              `yaml:"quiet_hours_start"`
+	QuietHoursEnd          string              `yaml:"quiet_hours_end"`
+	QuietHoursTimezone     string              `yaml:"quiet_hours_timezone"`
+	Categories             []string            `yaml:"categories"`
+	AutoDiscoverCategories bool                `yaml:"auto_discover_categories"`
+	TeamsWebhookURL        string              `yaml:"teams_webhook_url"`
+	CanaryWebhookURL       string              `yaml:"canary_webhook_url"`
+	FallbackWebhookURL     string              `yaml:"fallback_webhook_url"`
+	WebhookRevokedAfter    int                 `yaml:"webhook_revoked_after"`
+	Storefronts            []Storefront        `yaml:"storefronts"`
+	VerifyThumbnails       bool                `yaml:"verify_thumbnails"`
+	FallbackThumbnailURL   string              `yaml:"fallback_thumbnail_url"`
+	ImageProxyPrefix       string              `yaml:"image_proxy_prefix"`
+	NotifierEvents         map[string][]string `yaml:"notifier_events"`
+	PrimaryVariant         string              `yaml:"primary_variant"`
+	MessageTemplate        string              `yaml:"message_template"`
+	CanarySampleRate       float64             `yaml:"canary_sample_rate"`
 }
 
-func Load() (*Config, error) {
+// Storefront is an additional section of the store, such as Refurbished or
+// Early Access, swept with its own categories. Path is the storefront's path
+// below store.ui.com, starting with the region and language.
+type Storefront struct {
+	Name       string   `yaml:"name"`
+	Path       string   `yaml:"path"`
+	Categories []string `yaml:"categories"`
+}
+
+// DiscordConfig customizes the look of Discord alerts. Empty fields fall back
+// to the built-in defaults.
+type DiscordConfig struct {
+	Username      string `yaml:"username"`
+	AvatarURL     string `yaml:"avatar_url"`
+	EmbedColor    string `yaml:"embed_color"`
+	FooterText    string `yaml:"footer_text"`
+	AuthorIconURL string `yaml:"author_icon_url"`
+	// EmbedFields selects and orders the product fields shown in new and
+	// removed product alerts.
+	EmbedFields []string `yaml:"embed_fields"`
+	// Timestamp is TimestampDetected or TimestampSent. Empty means
+	// TimestampDetected.
+	Timestamp string `yaml:"timestamp"`
+}
+
+// Values accepted by discord.timestamp.
+const (
+	TimestampDetected = "detected"
+	TimestampSent     = "sent"
+)
+
+// EmbedFieldNames lists the values accepted by discord.embed_fields.
+var EmbedFieldNames = []string{"id", "title", "slug", "variant", "price", "stock", "category", "first_seen", "last_seen", "url"}
+
+// DefaultThumbnailURL replaces product images that can't be loaded when
+// verify_thumbnails is enabled and fallback_thumbnail_url is empty.
+const DefaultThumbnailURL = "https://tse3.mm.bing.net/th?id=OIP.RadjPrUUrLwqfVTEI5YqmwHaIV&pid=Api&P=0&w=300&h=300"
+
+// DefaultPath is the config file read when no path is given. Unlike an
+// explicit path, it is allowed to be missing.
+const DefaultPath = "./config.yml"
+
+// Values accepted by role
+const (
+	RolePrimary = "primary"
+	RoleStandby = "standby"
+)
+
+// Values accepted by primary_variant
+const (
+	PrimaryVariantFirst         = "first"
+	PrimaryVariantCheapest      = "cheapest"
+	PrimaryVariantMostExpensive = "most_expensive"
+)
+
+// Values accepted by bypass_cache
+const (
+	BypassCacheOff    = "off"
+	BypassCacheHeader = "header"
+	BypassCacheQuery  = "query"
+)
+
+// Values accepted by notify_mode
+const (
+	NotifyModeInstant = "instant"
+	NotifyModeDigest  = "digest"
+)
+
+// Load builds the configuration from the built-in defaults, the config file
+// at path and finally the environment, each overriding the previous one.
+// Command line flags are applied on top by the caller.
+func Load(path string) (*Config, error) {
 	cfg := &Config{
-		SaveBatchSize: 2,
-		HomeURL:       "https://store.ui.com/us/en",
-		ProductsFile:  "products.json",
+		SaveBatchSize:          2,
+		SaveInterval:           5 * time.Minute,
+		Role:                   RolePrimary,
+		StandbyRefreshInterval: time.Minute,
+		PrimaryVariant:         PrimaryVariantFirst,
+		BypassCache:            BypassCacheOff,
+		HomeURL:                "https://store.ui.com/us/en",
+		ProductsFile:           "products.json",
+		RemovalThreshold:       3,
+		EmptySweepThreshold:    3,
+		MaxAlertsPerSweep:      100,
+		WebhookRevokedAfter:    3,
+		UpcomingZeroPrice:      true,
+		MaxRetries:             3,
+		MaxBackoff:             time.Minute,
+		MaxRequestsPerSecond:   5,
+		MaxElapsedTime:         5 * time.Minute,
+		BreakerThreshold:       5,
+		BreakerCooldown:        15 * time.Minute,
+		WatchInterval:          time.Minute,
+		PollInterval:           30 * time.Second,
+		PollJitter:             0.2,
+		BuildIDTTL:             time.Hour,
+		LogLevel:               "info",
+		LogFormat:              "console",
+		NtfyServer:             "https://ntfy.sh",
+		SMTPPort:               587,
+		ProxyCooldown:          5 * time.Minute,
+		EventHistorySize:       100,
+		AlertCooldown:          10 * time.Minute,
+		HTTPTimeout:            10 * time.Second,
+		NotifyMaxAttempts:      3,
+		NotifyRetryBackoff:     2 * time.Second,
+		NotifyMode:             NotifyModeInstant,
+		DigestInterval:         24 * time.Hour,
+	}
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := unmarshal(path, data, cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+		// Fall through to defaults and environment
+	default:
+		return cfg, fmt.Errorf("failed to read %s: %w", path, err)
 	}
 
-	// Try environment variables first
 	if url := os.Getenv("DISCORD_WEBHOOK_URL"); url != "" {
 		cfg.DiscordWebhookURL = url
-		return cfg, nil
 	}
 
-	// Try config file
-	data, err := os.ReadFile("./config.yml")
-	if err != nil {
+	if err := applyEnv(cfg); err != nil {
 		return cfg, err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return cfg, err
+	return cfg, cfg.Validate()
+}
+
+// Validate checks values that can't be verified by the YAML decoder alone.
+func (c *Config) Validate() error {
+	if c.Discord.EmbedColor != "" {
+		if _, err := ParseColor(c.Discord.EmbedColor); err != nil {
+			return fmt.Errorf("invalid discord.embed_color: %w", err)
+		}
+	}
+
+	for _, name := range c.Discord.EmbedFields {
+		if !slices.Contains(EmbedFieldNames, name) {
+			return fmt.Errorf("unknown discord.embed_fields entry %q, valid names are: %s", name, strings.Join(EmbedFieldNames, ", "))
+		}
+	}
+
+	switch c.Discord.Timestamp {
+	case "", TimestampDetected, TimestampSent:
+	default:
+		return fmt.Errorf("discord.timestamp must be %q or %q", TimestampDetected, TimestampSent)
+	}
+
+	if c.SaveBatchSize < 1 {
+		return fmt.Errorf("save_batch_size must be at least 1")
+	}
+	switch c.Role {
+	case RolePrimary:
+	case RoleStandby:
+		if c.Stateless {
+			return fmt.Errorf("a standby needs the products file, it can't be stateless")
+		}
+	default:
+		return fmt.Errorf("role must be %q or %q", RolePrimary, RoleStandby)
+	}
+	switch c.PrimaryVariant {
+	case PrimaryVariantFirst, PrimaryVariantCheapest, PrimaryVariantMostExpensive:
+	default:
+		return fmt.Errorf("primary_variant must be %q, %q or %q", PrimaryVariantFirst, PrimaryVariantCheapest, PrimaryVariantMostExpensive)
+	}
+
+	switch c.BypassCache {
+	case BypassCacheOff, BypassCacheHeader, BypassCacheQuery:
+	default:
+		return fmt.Errorf("bypass_cache must be %q, %q or %q", BypassCacheOff, BypassCacheHeader, BypassCacheQuery)
+	}
+
+	if c.StandbyRefreshInterval <= 0 {
+		return fmt.Errorf("standby_refresh_interval must be positive")
+	}
+
+	if c.SaveInterval <= 0 {
+		return fmt.Errorf("save_interval must be positive")
+	}
+
+	if c.HTTPTimeout <= 0 {
+		return fmt.Errorf("http_timeout must be positive")
+	}
+
+	if c.NotifyMaxAttempts < 1 {
+		return fmt.Errorf("notify_max_attempts must be at least 1")
+	}
+	if c.NotifyRetryBackoff < 0 {
+		return fmt.Errorf("notify_retry_backoff must not be negative")
+	}
+
+	switch c.NotifyMode {
+	case NotifyModeInstant:
+	case NotifyModeDigest:
+		if c.DigestInterval <= 0 {
+			return fmt.Errorf("digest_interval must be positive")
+		}
+	default:
+		return fmt.Errorf("notify_mode must be %q or %q", NotifyModeInstant, NotifyModeDigest)
+	}
+
+	if _, err := c.QuietHours(); err != nil {
+		return err
+	}
+
+	if _, err := c.TitleFilter(); err != nil {
+		return err
+	}
+
+	if c.MinPrice < 0 || c.MaxPrice < 0 {
+		return fmt.Errorf("min_price and max_price must not be negative")
+	}
+	if c.MinPrice > 0 && c.MaxPrice > 0 && c.MinPrice > c.MaxPrice {
+		return fmt.Errorf("min_price %.2f is above max_price %.2f, no product would be alerted", c.MinPrice, c.MaxPrice)
+	}
+
+	if _, err := message.Parse(c.MessageTemplate); err != nil {
+		return err
+	}
+
+	for i, storefront := range c.Storefronts {
+		if storefront.Name == "" {
+			return fmt.Errorf("storefronts[%d]: name is required", i)
+		}
+		if len(strings.Split(strings.Trim(storefront.Path, "/"), "/")) < 2 {
+			return fmt.Errorf("storefronts[%d]: path must start with the region and language, e.g. us/en", i)
+		}
+		if len(storefront.Categories) == 0 {
+			return fmt.Errorf("storefronts[%d]: at least one category is required", i)
+		}
+	}
+
+	if c.MatrixHomeserver != "" {
+		u, err := url.Parse(c.MatrixHomeserver)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("matrix_homeserver must be an http or https URL")
+		}
+	}
+
+	if c.NATSURL != "" && (c.NATSSubject == "" || strings.ContainsAny(c.NATSSubject, " \t\r\n")) {
+		return fmt.Errorf("nats_subject must be set and must not contain whitespace when nats_url is set")
+	}
+
+	if c.SOCKS5Proxy != "" {
+		u, err := url.Parse(c.SOCKS5Proxy)
+		if err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") || u.Host == "" {
+			return fmt.Errorf("socks5_proxy must look like socks5://host:port")
+		}
+		if len(c.Proxies) > 0 {
+			return fmt.Errorf("socks5_proxy and proxies can't be used together")
+		}
+	}
+
+	for region := range c.RegionProxies {
+		if region == "" || strings.Contains(region, "/") {
+			return fmt.Errorf("region_proxies: %q is not a region code such as uk", region)
+		}
+	}
+
+	if c.CanarySampleRate < 0 || c.CanarySampleRate > 1 {
+		return fmt.Errorf("canary_sample_rate must be between 0 and 1")
+	}
+
+	if c.PruneAfter < 0 {
+		return fmt.Errorf("prune_after must not be negative")
+	}
+
+	if c.EmptySweepThreshold < 0 {
+		return fmt.Errorf("empty_sweep_threshold must not be negative")
+	}
+
+	if c.MaxAlertsPerSweep < 0 {
+		return fmt.Errorf("max_alerts_per_sweep must not be negative")
+	}
+
+	if home, err := url.Parse(c.HomeURL); err != nil || home.Scheme == "" || home.Host == "" {
+		return fmt.Errorf("home_url must be an absolute URL such as https://store.ui.com/us/en")
+	}
+
+	if c.WebhookRevokedAfter < 0 {
+		return fmt.Errorf("webhook_revoked_after must not be negative")
+	}
+
+	if c.MaxRequestsPerSecond < 0 {
+		return fmt.Errorf("max_requests_per_second must not be negative")
+	}
+
+	if c.MaxBackoff <= 0 {
+		return fmt.Errorf("max_backoff must be positive")
+	}
+
+	if c.MaxElapsedTime < 0 {
+		return fmt.Errorf("max_elapsed_time must not be negative")
+	}
+
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("poll_interval must be positive")
+	}
+
+	if c.AlertCooldown < 0 {
+		return fmt.Errorf("alert_cooldown must not be negative")
+	}
+
+	if c.BuildIDTTL < 0 {
+		return fmt.Errorf("build_id_ttl must not be negative")
+	}
+
+	if c.SMTPHost != "" && (c.EmailFrom == "" || len(c.EmailTo) == 0) {
+		return fmt.Errorf("email_from and email_to are required when smtp_host is set")
+	}
+
+	if c.PollJitter < 0 || c.PollJitter >= 1 {
+		return fmt.Errorf("poll_jitter must be between 0 and 1")
+	}
+
+	if c.BreakerThreshold < 0 {
+		return fmt.Errorf("breaker_threshold must not be negative")
+	}
+	if c.BreakerThreshold > 0 && c.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown must be positive")
+	}
+
+	if len(c.Watchlist) > 0 && c.WatchInterval <= 0 {
+		return fmt.Errorf("watch_interval must be positive")
+	}
+
+	return nil
+}
+
+// ParseColor parses a color given as hex ("#e91e63", "0xe91e63") or as a
+// decimal integer ("15277667").
+func ParseColor(value string) (int, error) {
+	value = strings.TrimSpace(value)
+
+	var (
+		color int64
+		err   error
+	)
+	switch {
+	case strings.HasPrefix(value, "#"):
+		color, err = strconv.ParseInt(value[1:], 16, 32)
+	case strings.HasPrefix(strings.ToLower(value), "0x"):
+		color, err = strconv.ParseInt(value[2:], 16, 32)
+	default:
+		color, err = strconv.ParseInt(value, 10, 32)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a hex or decimal color", value)
+	}
+
+	if color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("%q is outside the RGB color range", value)
 	}
 
-	return cfg, nil
+	return int(color), nil
 }
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadYAML loads a config file with the given contents.
+func loadYAML(t *testing.T, contents string) (*Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return Load(path)
+}
+
+func TestValidatePriceRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{"unset", "", false},
+		{"range", "min_price: 100\nmax_price: 200\n", false},
+		{"equal bounds", "min_price: 150\nmax_price: 150\n", false},
+		{"min only", "min_price: 500\n", false},
+		{"max only", "max_price: 50\n", false},
+		{"inverted", "min_price: 200\nmax_price: 100\n", true},
+		{"negative min", "min_price: -1\n", true},
+		{"negative max", "max_price: -1\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadYAML(t, tt.yaml)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load(%q) error = %v, want error %v", tt.yaml, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix starts the environment variables that override config keys,
+// for example UNIFI_MONITOR_POLL_INTERVAL for poll_interval. Keys of nested
+// sections are joined with an underscore, as in
+// UNIFI_MONITOR_DISCORD_EMBED_COLOR.
+const EnvPrefix = "UNIFI_MONITOR_"
+
+// applyEnv overrides config keys with the matching environment variables.
+// Strings are used as is, lists of strings may be comma separated, and
+// every other value is parsed with the same YAML syntax as the config file.
+func applyEnv(cfg *Config) error {
+	return applyEnvTo(reflect.ValueOf(cfg).Elem(), EnvPrefix)
+}
+
+func applyEnvTo(value reflect.Value, prefix string) error {
+	for i := 0; i < value.NumField(); i++ {
+		key, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(key)
+		field := value.Field(i)
+
+		if raw, ok := os.LookupEnv(name); ok {
+			if err := setFromEnv(field, raw); err != nil {
+				return fmt.Errorf("invalid %s %q, expected a value of type %s", name, raw, field.Type())
+			}
+		}
+
+		if field.Kind() == reflect.Struct && field.Type().PkgPath() == value.Type().PkgPath() {
+			if err := applyEnvTo(field, name+"_"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setFromEnv(field reflect.Value, raw string) error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(raw)
+		return nil
+	case field.Type() == reflect.TypeOf([]string(nil)) && !strings.HasPrefix(strings.TrimSpace(raw), "["):
+		var values []string
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+		return nil
+	}
+
+	parsed := reflect.New(field.Type())
+	if err := yaml.UnmarshalStrict([]byte(raw), parsed.Interface()); err != nil {
+		return err
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// unmarshal decodes the config file according to its extension: .toml,
+// .json, or YAML for anything else. TOML and JSON documents are converted
+// to YAML first, so every format uses the same keys and duration syntax.
+func unmarshal(path string, data []byte, cfg *Config) error {
+	var document map[string]any
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		if err := toml.Unmarshal(data, &document); err != nil {
+			return err
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &document); err != nil {
+			return err
+		}
+	default:
+		return yaml.Unmarshal(data, cfg)
+	}
+
+	converted, err := yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(converted, cfg)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily window during which alerts are held back.
+type QuietHours struct {
+	// Start and End are offsets from midnight. The window spans midnight
+	// when End is before Start.
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// QuietHours returns the configured quiet hours window, or nil when
+// quiet_hours_start and quiet_hours_end are unset.
+func (c *Config) QuietHours() (*QuietHours, error) {
+	if c.QuietHoursStart == "" && c.QuietHoursEnd == "" {
+		return nil, nil
+	}
+	if c.QuietHoursStart == "" || c.QuietHoursEnd == "" {
+		return nil, fmt.Errorf("quiet_hours_start and quiet_hours_end must be set together")
+	}
+
+	start, err := parseClock(c.QuietHoursStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet_hours_start: %w", err)
+	}
+	end, err := parseClock(c.QuietHoursEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet_hours_end: %w", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("quiet_hours_start and quiet_hours_end must differ")
+	}
+
+	location := time.Local
+	if c.QuietHoursTimezone != "" {
+		location, err = time.LoadLocation(c.QuietHoursTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet_hours_timezone: %w", err)
+		}
+	}
+
+	return &QuietHours{Start: start, End: end, Location: location}, nil
+}
+
+// parseClock parses a time of day such as 22:00 into its offset from
+// midnight.
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day such as 22:00", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// Active reports whether t falls within the window.
+func (q *QuietHours) Active(t time.Time) bool {
+	offset := sinceMidnight(t.In(q.Location))
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// NextEnd returns the first end of the window after t.
+func (q *QuietHours) NextEnd(t time.Time) time.Time {
+	t = t.In(q.Location)
+	year, month, day := t.Date()
+	hour, minute := int(q.End/time.Hour), int(q.End%time.Hour/time.Minute)
+	end := time.Date(year, month, day, hour, minute, 0, 0, q.Location)
+	if !end.After(t) {
+		end = time.Date(year, month, day+1, hour, minute, 0, 0, q.Location)
+	}
+	return end
+}
+
+// sinceMidnight returns the wall clock time of t as an offset from midnight,
+// so daylight saving changes don't shift the window.
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// TitleFilter decides from a product's title whether it is alerted.
+type TitleFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// TitleFilter compiles title_include_patterns and title_exclude_patterns,
+// or returns nil when both are empty.
+func (c *Config) TitleFilter() (*TitleFilter, error) {
+	if len(c.TitleIncludePatterns) == 0 && len(c.TitleExcludePatterns) == 0 {
+		return nil, nil
+	}
+
+	include, err := compilePatterns("title_include_patterns", c.TitleIncludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compilePatterns("title_exclude_patterns", c.TitleExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	return &TitleFilter{include: include, exclude: exclude}, nil
+}
+
+func compilePatterns(name string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s[%d] %q: %w", name, i, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Allows reports whether a product with the title is alerted: it matches an
+// include pattern, if there are any, and no exclude pattern. A nil filter
+// allows every title.
+func (f *TitleFilter) Allows(title string) bool {
+	if f == nil {
+		return true
+	}
+
+	for _, re := range f.exclude {
+		if re.MatchString(title) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
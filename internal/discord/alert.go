@@ -0,0 +1,28 @@
+package discord
+
+import "time"
+
+const alertColor = 15548997
+
+// SendAlert posts an operational message, such as a warning that the
+// monitor may be broken, to the default webhook.
+func (w *Webhook) SendAlert(title, message string) error {
+	if w.url == "" {
+		return nil
+	}
+
+	return w.send(w.url, []Embed{{
+		Title:     title,
+		Color:     alertColor,
+		Timestamp: time.Now(),
+		Author: Author{
+			Name:     "⚠️ **Monitor Alert** ⚠️",
+			Icon_URL: w.authorIconURL,
+		},
+		Description: truncate(message, maxDescriptionLength),
+		Footer: Footer{
+			Text:     w.footerText,
+			Icon_url: w.authorIconURL,
+		},
+	}})
+}
@@ -0,0 +1,81 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// Image is the large image shown below an embed.
+type Image struct {
+	Url string `json:"url"`
+}
+
+// detailFields lists the specifications, the products an accessory works
+// with and per-SKU stock from a product's page. Each becomes a single field
+// cut to Discord's field length.
+func detailFields(detail *models.ProductDetail) []Field {
+	var fields []Field
+
+	if len(detail.Specs) > 0 {
+		lines := make([]string, 0, len(detail.Specs))
+		for _, spec := range detail.Specs {
+			lines = append(lines, fmt.Sprintf("**%s:** %s", spec.Name, spec.Value))
+		}
+		fields = append(fields, Field{Name: "Specifications", Value: truncate(strings.Join(lines, "\n"), maxFieldLength)})
+	}
+
+	if len(detail.CompatibleWith) > 0 {
+		lines := make([]string, 0, len(detail.CompatibleWith))
+		for _, product := range detail.CompatibleWith {
+			if product.URL != "" {
+				lines = append(lines, fmt.Sprintf("[%s](%s)", product.Title, product.URL))
+			} else {
+				lines = append(lines, product.Title)
+			}
+		}
+		fields = append(fields, Field{Name: "Works With", Value: truncateLines(lines, maxFieldLength)})
+	}
+
+	if len(detail.Stock) > 0 {
+		lines := make([]string, 0, len(detail.Stock))
+		for _, stock := range detail.Stock {
+			lines = append(lines, fmt.Sprintf("`%s` %s", stock.SKU, stockLabel(models.ParseAvailability(stock.Status))))
+		}
+		fields = append(fields, Field{Name: "Stock per SKU", Value: truncate(strings.Join(lines, "\n"), maxFieldLength)})
+	}
+
+	return fields
+}
+
+// detailImage returns the first gallery image that isn't already the
+// thumbnail, or nil when there is none.
+func detailImage(event models.Event) *Image {
+	if event.Detail == nil {
+		return nil
+	}
+	for _, url := range event.Detail.Images {
+		if url != event.Product.Thumbnail.URL {
+			return &Image{Url: url}
+		}
+	}
+	return nil
+}
+
+// truncateLines joins as many whole lines as fit within limit, so links
+// aren't cut in half, and notes how many were left out.
+func truncateLines(lines []string, limit int) string {
+	value := strings.Join(lines, "\n")
+	if len([]rune(value)) <= limit {
+		return value
+	}
+
+	for kept := len(lines) - 1; kept > 0; kept-- {
+		value = strings.Join(lines[:kept], "\n") + fmt.Sprintf("\n+%d more", len(lines)-kept)
+		if len([]rune(value)) <= limit {
+			return value
+		}
+	}
+	return truncate(lines[0], limit)
+}
@@ -0,0 +1,119 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"all-unifi-monitor/internal/models"
+)
+
+const (
+	maxEmbedFields = 25
+	otherCategory  = "other"
+)
+
+// digestLabels names each event type in digest summaries and listings.
+var digestLabels = map[models.EventType]string{
+	models.EventNew:            "New",
+	models.EventUpcoming:       "Upcoming",
+	models.EventAvailable:      "Now available",
+	models.EventRemoved:        "Removed",
+	models.EventBackInStock:    "Back in stock",
+	models.EventPriceChange:    "Price change",
+	models.EventSale:           "Sale",
+	models.EventUpdated:        "Updated",
+	models.EventInStock:        "In stock",
+	models.EventSoldOut:        "Sold out",
+	models.EventNewSubCategory: "New subcategory",
+}
+
+// SendDigest summarizes events in a single message per destination webhook,
+// with a count of each event type and the affected products grouped by
+// category.
+func (w *Webhook) SendDigest(events []models.Event) error {
+	var urls []string
+	eventsByURL := make(map[string][]models.Event)
+	for _, event := range events {
+		url := w.urlFor(event.Category)
+		if url == "" {
+			continue
+		}
+		if _, ok := eventsByURL[url]; !ok {
+			urls = append(urls, url)
+		}
+		eventsByURL[url] = append(eventsByURL[url], event)
+	}
+
+	for i, url := range urls {
+		if i > 0 {
+			time.Sleep(batchDelay)
+		}
+		if err := w.send(url, []Embed{w.buildDigestEmbed(eventsByURL[url])}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) buildDigestEmbed(events []models.Event) Embed {
+	counts := make(map[models.EventType]int)
+	var categories []string
+	lines := make(map[string][]string)
+	for _, event := range events {
+		counts[event.Type]++
+
+		category := event.Category
+		if category == "" {
+			category = otherCategory
+		}
+		if _, ok := lines[category]; !ok {
+			categories = append(categories, category)
+		}
+		lines[category] = append(lines[category], fmt.Sprintf("%s: [%s](%s)",
+			digestLabels[event.Type], event.Title(), event.URL()))
+	}
+
+	var summary []string
+	for _, eventType := range []models.EventType{
+		models.EventNew, models.EventUpcoming, models.EventAvailable, models.EventInStock, models.EventBackInStock,
+		models.EventSoldOut, models.EventPriceChange, models.EventSale, models.EventUpdated, models.EventRemoved, models.EventNewSubCategory,
+	} {
+		if counts[eventType] > 0 {
+			summary = append(summary, fmt.Sprintf("**%s:** %d", digestLabels[eventType], counts[eventType]))
+		}
+	}
+
+	fields := make([]Field, 0, min(len(categories), maxEmbedFields))
+	for _, category := range categories {
+		if len(fields) == maxEmbedFields {
+			break
+		}
+		fields = append(fields, Field{
+			Name:  category,
+			Value: truncate(strings.Join(lines[category], "\n"), maxFieldLength),
+		})
+	}
+
+	color := 3447003
+	if w.hasColor {
+		color = w.color
+	}
+
+	return Embed{
+		Title:     fmt.Sprintf("%d changes", len(events)),
+		Color:     color,
+		Timestamp: time.Now(),
+		Author: Author{
+			Name:     "🗞️ **Digest** 🗞️",
+			Icon_URL: w.authorIconURL,
+		},
+		Description: strings.Join(summary, "\n"),
+		Fields:      fields,
+		Footer: Footer{
+			Text:     w.footerText,
+			Icon_url: w.authorIconURL,
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package discord
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+var webhookPathPattern = regexp.MustCompile(`^/api(/v\d+)?/webhooks/\d+/[A-Za-z0-9_-]+/?$`)
+
+var webhookHosts = map[string]bool{
+	"discord.com":           true,
+	"discordapp.com":        true,
+	"ptb.discord.com":       true,
+	"canary.discord.com":    true,
+	"ptb.discordapp.com":    true,
+	"canary.discordapp.com": true,
+}
+
+// validateWebhook checks that raw looks like a Discord webhook URL of the form
+// https://discord.com/api/webhooks/<id>/<token>.
+func validateWebhook(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("malformed webhook URL: %w", err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("webhook URL must use https")
+	}
+
+	if !webhookHosts[u.Host] {
+		return fmt.Errorf("webhook host %q is not discord.com or discordapp.com", u.Host)
+	}
+
+	if !webhookPathPattern.MatchString(u.Path) {
+		return fmt.Errorf("webhook path must look like /api/webhooks/<id>/<token>")
+	}
+
+	return nil
+}
+
+// Validate checks every configured webhook URL. With verify set, each webhook
+// is also fetched to confirm it still exists.
+func (w *Webhook) Validate(verify bool) error {
+	return w.ValidateAs("discord_webhook_url", verify)
+}
+
+// ValidateAs is Validate for a webhook whose default URL comes from the
+// given setting, which errors are reported against.
+func (w *Webhook) ValidateAs(setting string, verify bool) error {
+	urls := make(map[string]string)
+	if w.url != "" {
+		urls[setting] = w.url
+	}
+	for category, categoryURL := range w.categoryURLs {
+		urls[fmt.Sprintf("category_webhooks.%s", category)] = categoryURL
+	}
+
+	for name, webhookURL := range urls {
+		if err := validateWebhook(webhookURL); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		if verify {
+			if err := w.verify(webhookURL); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) verify(webhookURL string) error {
+	req, err := http.NewRequest(http.MethodGet, webhookURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to verify webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusUnauthorized:
+		return fmt.Errorf("webhook does not exist or the token is wrong (status %d)", resp.StatusCode)
+	default:
+		return fmt.Errorf("unexpected status code verifying webhook: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,39 @@
+package discord
+
+import (
+	"fmt"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// maxFields is the most fields Discord accepts in a single embed.
+const maxFields = 25
+
+// variantListFields lists the ID and price of every variant, one field each.
+func variantListFields(variants []models.Variant) []Field {
+	fields := make([]Field, 0, len(variants))
+	for _, variant := range variants {
+		fields = append(fields, Field{
+			Name:   valueOr(variant.ID, notAvailable),
+			Value:  variant.Price(),
+			Inline: true,
+		})
+	}
+	return fields
+}
+
+// capFields keeps an embed within Discord's field limit. When there are too
+// many fields, the last one that fits is replaced by a note saying how many
+// were left out.
+func capFields(fields []Field) []Field {
+	if len(fields) <= maxFields {
+		return fields
+	}
+
+	kept := fields[:maxFields-1]
+	return append(kept, Field{
+		Name:   fmt.Sprintf("+%d more", len(fields)-len(kept)),
+		Value:  "See the store page for the rest",
+		Inline: true,
+	})
+}
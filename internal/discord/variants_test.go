@@ -0,0 +1,48 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+)
+
+func TestBuildEmbedCapsVariantFields(t *testing.T) {
+	product := models.Product{ID: "A", Title: "Product A"}
+	for i := range 30 {
+		product.Variants = append(product.Variants, models.Variant{
+			ID:           fmt.Sprintf("A-%d", i),
+			DisplayPrice: models.DisplayPrice{Amount: 1000 + i*100, Currency: "USD"},
+		})
+	}
+
+	w := New("", config.DiscordConfig{})
+	embed := w.buildEmbed(models.Event{Type: models.EventNew, Product: product})
+
+	if !strings.Contains(embed.Description, "**30 variants:** $10.00 – $39.00") {
+		t.Errorf("description %q is missing the variant count and price range", embed.Description)
+	}
+
+	// The price range and 30 variants make 31 fields
+	if len(embed.Fields) != maxFields {
+		t.Fatalf("embed has %d fields, want %d", len(embed.Fields), maxFields)
+	}
+	if got := embed.Fields[0]; got.Name != "Price Range" || got.Value != "$10.00 – $39.00" {
+		t.Errorf("first field = %+v, want the price range", got)
+	}
+	if got := embed.Fields[maxFields-2]; got.Name != "A-22" {
+		t.Errorf("last variant field = %+v, want A-22", got)
+	}
+	if got := embed.Fields[maxFields-1].Name; got != "+7 more" {
+		t.Errorf("last field = %q, want %q", got, "+7 more")
+	}
+}
+
+func TestCapFields(t *testing.T) {
+	fields := make([]Field, maxFields)
+	if got := capFields(fields); len(got) != maxFields {
+		t.Errorf("capFields() of %d fields kept %d", maxFields, len(got))
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"all-unifi-monitor/internal/config"
 	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/message"
 	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
 
 	http "github.com/saucesteals/fhttp"
 )
@@ -27,6 +32,7 @@ type Embed struct {
 	Description string    `json:"description"`
 	Fields      []Field   `json:"fields"`
 	Footer      Footer    `json:"footer"`
+	Image       *Image    `json:"image,omitempty"`
 }
 
 type Thumbnail struct {
@@ -50,54 +56,465 @@ type Hook struct {
 	Embeds     []Embed `json:"embeds"`
 }
 
+const (
+	maxEmbedsPerMessage  = 10
+	maxFieldLength       = 1024
+	maxDescriptionLength = 4096
+	batchDelay           = 2 * time.Second
+
+	// Rate limited requests are retried a bounded number of times
+	maxRetries    = 3
+	retryDelay    = 5 * time.Second
+	maxRetryDelay = time.Minute
+
+	defaultUsername = "Unifi Store Monitor"
+	notAvailable    = "N/A"
+	defaultIconURL  = "https://tse3.mm.bing.net/th?id=OIP.RadjPrUUrLwqfVTEI5YqmwHaIV&pid=Api&P=0&w=300&h=300"
+)
+
 type Webhook struct {
-	url        string
-	httpClient *customhttp.Client
+	url           string
+	categoryURLs  map[string]string
+	httpClient    *customhttp.Client
+	username      string
+	avatarURL     string
+	footerText    string
+	authorIconURL string
+	color         int
+	hasColor      bool
+	dryRun        bool
+	fields        []string
+	sendTime      bool
+	retry         customhttp.RetryPolicy
+	template      *message.Template
 }
 
-func New(url string) *Webhook {
-	return &Webhook{
-		url:        url,
-		httpClient: customhttp.NewClient(),
+func New(url string, cfg config.DiscordConfig) *Webhook {
+	w := &Webhook{
+		url:           url,
+		httpClient:    customhttp.NewClient(),
+		username:      valueOr(cfg.Username, defaultUsername),
+		avatarURL:     valueOr(cfg.AvatarURL, defaultIconURL),
+		footerText:    valueOr(cfg.FooterText, defaultUsername),
+		authorIconURL: valueOr(cfg.AuthorIconURL, defaultIconURL),
+		fields:        cfg.EmbedFields,
+		sendTime:      cfg.Timestamp == config.TimestampSent,
 	}
+	if len(w.fields) == 0 {
+		w.fields = defaultEmbedFields
+	}
+
+	// embed_color has already been validated by config.Load
+	if cfg.EmbedColor != "" {
+		if color, err := config.ParseColor(cfg.EmbedColor); err == nil {
+			w.color = color
+			w.hasColor = true
+		}
+	}
+
+	return w
 }
 
-func (w *Webhook) SendProduct(product models.Product) error {
-	embed := Embed{
-		Title:     product.Title,
-		Color:     15277667,
-		Url:       fmt.Sprintf("https://store.ui.com/us/en/products/%s", product.Slug),
-		Timestamp: time.Now(),
-		Thumbnail: Thumbnail{
-			Url: product.Thumbnail.URL,
-		},
-		Author: Author{
-			Name:     "🎉 **New Product Alert!** 🎉",
-			Icon_URL: "https://tse3.mm.bing.net/th?id=OIP.RadjPrUUrLwqfVTEI5YqmwHaIV&pid=Api&P=0&w=300&h=300",
-		},
-		Description: fmt.Sprintf("%s\n", product.ShortDescription),
-		Fields: []Field{
-			{
+func (w *Webhook) Name() string {
+	return "discord"
+}
+
+// SetCategoryWebhooks routes events from the given categories to their own
+// webhook URL. Other categories keep using the default URL.
+func (w *Webhook) SetCategoryWebhooks(urls map[string]string) {
+	w.categoryURLs = urls
+}
+
+func (w *Webhook) urlFor(category string) string {
+	if url, ok := w.categoryURLs[category]; ok {
+		return url
+	}
+	return w.url
+}
+
+// SetMessageTemplate makes embeds use the rendered template as their
+// description.
+func (w *Webhook) SetMessageTemplate(template *message.Template) {
+	w.template = template
+}
+
+// SetDryRun makes the webhook log rendered payloads instead of posting them.
+func (w *Webhook) SetDryRun(enabled bool) {
+	w.dryRun = enabled
+}
+
+// SetTimeout changes how long a webhook request may take.
+func (w *Webhook) SetTimeout(timeout time.Duration) {
+	w.httpClient.SetTimeout(timeout)
+}
+
+// SetRetryPolicy retries messages that failed with a network error or a 5xx
+// response.
+func (w *Webhook) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	w.retry = policy
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func variantFields(variants []models.Variant) []Field {
+	fields := make([]Field, 0, len(variants)*2)
+	for _, variant := range variants {
+		fields = append(fields,
+			Field{
 				Name:   "Variant",
-				Value:  product.Variants[0].ID,
+				Value:  variant.ID,
 				Inline: true,
 			},
-			{
+			Field{
 				Name:   "Price",
-				Value:  fmt.Sprintf("$%d.%02d", product.Variants[0].DisplayPrice.Amount/100, product.Variants[0].DisplayPrice.Amount%100),
+				Value:  variant.Price(),
 				Inline: true,
 			},
+		)
+	}
+	return fields
+}
+
+// defaultEmbedFields are shown when discord.embed_fields is empty.
+var defaultEmbedFields = []string{"variant", "price"}
+
+// productFields renders the configured discord.embed_fields for a product.
+// Variant details come from the product's first variant. Products with
+// several variants show their price range instead, and their variants are
+// listed separately by buildEmbed.
+func productFields(product models.Product, names []string) []Field {
+	variant, hasVariant := models.Variant{}, len(product.Variants) > 0
+	severalVariants := len(product.Variants) > 1
+	if hasVariant {
+		variant = product.Variants[0]
+	} else {
+		logger.Warning().Str("id", product.ID).Msg("Product has no variants, omitting price")
+	}
+
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		var field Field
+		switch name {
+		case "id":
+			field = Field{Name: "ID", Value: product.ID, Inline: true}
+		case "title":
+			field = Field{Name: "Title", Value: product.Title}
+		case "slug":
+			field = Field{Name: "Slug", Value: product.Slug, Inline: true}
+		case "variant":
+			if severalVariants {
+				continue
+			}
+			field = Field{Name: "Variant", Value: notAvailable, Inline: true}
+			if hasVariant {
+				field.Value = variant.ID
+			}
+		case "price":
+			field = Field{Name: "Price", Value: notAvailable, Inline: true}
+			if severalVariants {
+				field = Field{Name: "Price Range", Value: product.PriceRange(), Inline: true}
+			} else if hasVariant {
+				field.Value = variant.Price()
+			}
+		case "stock":
+			field = Field{Name: "Stock", Value: stockLabel(product.Availability()), Inline: true}
+		case "category":
+			field = Field{Name: "Category", Value: strings.Join(product.Categories, ", "), Inline: true}
+		case "first_seen":
+			field = Field{Name: "First Seen", Value: discordTime(product.FirstSeen), Inline: true}
+		case "last_seen":
+			field = Field{Name: "Last Seen", Value: discordTime(product.LastSeen), Inline: true}
+		case "url":
+			field = Field{Name: "URL", Value: product.URL()}
+		default:
+			continue
+		}
+		// Discord rejects fields with an empty value
+		field.Value = truncate(valueOr(field.Value, notAvailable), maxFieldLength)
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func stockLabel(availability models.Availability) string {
+	switch availability {
+	case models.AvailabilityInStock:
+		return "In stock"
+	case models.AvailabilityComingSoon:
+		return "Coming soon"
+	case models.AvailabilitySoldOut:
+		return "Sold out"
+	default:
+		return notAvailable
+	}
+}
+
+// discordTime renders t as a timestamp shown in the reader's time zone.
+func discordTime(t time.Time) string {
+	if t.IsZero() {
+		return notAvailable
+	}
+	return fmt.Sprintf("<t:%d:f>", t.Unix())
+}
+
+func priceChangeFields(event models.Event) []Field {
+	fields := make([]Field, 0, len(event.Variants)*2)
+	for _, variant := range event.Variants {
+		oldPrice := event.OldPrices[variant.ID]
+		newPrice := variant.DisplayPrice.Amount
+		currency := variant.DisplayPrice.Currency
+		price := fmt.Sprintf("%s → %s", models.FormatPrice(oldPrice, currency), models.FormatPrice(newPrice, currency))
+		if oldPrice > 0 && newPrice < oldPrice {
+			price += fmt.Sprintf(" (-%d%%)", (oldPrice-newPrice)*100/oldPrice)
+		}
+		fields = append(fields,
+			Field{
+				Name:   "Variant",
+				Value:  variant.ID,
+				Inline: true,
+			},
+			Field{
+				Name:   "Price",
+				Value:  price,
+				Inline: true,
+			},
+		)
+	}
+	return fields
+}
+
+// updateFields shows the before and after value of each changed text field.
+func updateFields(event models.Event) []Field {
+	if event.Previous == nil {
+		return nil
+	}
+
+	var fields []Field
+	changes := []struct {
+		name     string
+		old, new string
+	}{
+		{"Title", event.Previous.Title, event.Product.Title},
+		{"Description", event.Previous.ShortDescription, event.Product.ShortDescription},
+	}
+	for _, change := range changes {
+		if change.old == change.new {
+			continue
+		}
+		fields = append(fields, Field{
+			Name:  change.name,
+			Value: truncate(fmt.Sprintf("**Before:** %s\n**After:** %s", change.old, change.new), maxFieldLength),
+		})
+	}
+	return fields
+}
+
+func truncate(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func (w *Webhook) SendProduct(product models.Product) error {
+	return w.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (w *Webhook) SendEvent(event models.Event) error {
+	url := w.urlFor(event.Category)
+	if url == "" {
+		return nil
+	}
+	return w.send(url, []Embed{w.buildEmbed(event)})
+}
+
+// SendEvents announces several events using as few messages as possible.
+// Events are grouped by destination webhook and Discord accepts up to 10
+// embeds per message, so larger batches are split across multiple calls.
+func (w *Webhook) SendEvents(events []models.Event) error {
+	var urls []string
+	embedsByURL := make(map[string][]Embed)
+	for _, event := range events {
+		url := w.urlFor(event.Category)
+		if url == "" {
+			continue
+		}
+		if _, ok := embedsByURL[url]; !ok {
+			urls = append(urls, url)
+		}
+		embedsByURL[url] = append(embedsByURL[url], w.buildEmbed(event))
+	}
+
+	sent := 0
+	for _, url := range urls {
+		embeds := embedsByURL[url]
+		for start := 0; start < len(embeds); start += maxEmbedsPerMessage {
+			end := min(start+maxEmbedsPerMessage, len(embeds))
+
+			if sent > 0 {
+				time.Sleep(batchDelay)
+			}
+
+			if err := w.send(url, embeds[start:end]); err != nil {
+				return err
+			}
+			sent++
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) buildEmbed(event models.Event) Embed {
+	if event.Type == models.EventNewSubCategory {
+		return w.buildSubCategoryEmbed(event)
+	}
+
+	product := event.Product
+
+	authorName := "🎉 **New Product Alert!** 🎉"
+	color := 15277667
+	fields := productFields(product, w.fields)
+
+	switch event.Type {
+	case models.EventUpcoming:
+		authorName = "🔜 **Upcoming Product** 🔜"
+		color = 10181046
+	case models.EventAvailable:
+		authorName = "✅ **Now Available** ✅"
+		color = 5763719
+	case models.EventRemoved:
+		authorName = "🚫 **Product Removed** 🚫"
+		color = 10038562
+	case models.EventBackInStock:
+		authorName = "🔁 **New Variant / Back in Stock** 🔁"
+		color = 3066993
+		fields = variantFields(event.Variants)
+	case models.EventPriceChange:
+		authorName = "💲 **Price Change** 💲"
+		color = 15844367
+		fields = priceChangeFields(event)
+	case models.EventSale:
+		authorName = "🏷️ **Sale** 🏷️"
+		color = 15105570
+		fields = priceChangeFields(event)
+	case models.EventUpdated:
+		authorName = "✏️ **Product Updated** ✏️"
+		color = 9807270
+		fields = updateFields(event)
+	case models.EventInStock:
+		authorName = "🚀 **Now In Stock** 🚀"
+		color = 5763719
+		fields = variantFields(event.Variants)
+	case models.EventSoldOut:
+		authorName = "⛔ **Sold Out** ⛔"
+		color = 10038562
+		fields = variantFields(event.Variants)
+	}
+
+	if event.Detail != nil {
+		fields = append(fields, detailFields(event.Detail)...)
+	}
+
+	description := product.ShortDescription
+	// New and removed products list every variant after the other fields
+	if listsVariants(event.Type) && len(product.Variants) > 1 {
+		if description != "" {
+			description += "\n"
+		}
+		description += fmt.Sprintf("**%d variants:** %s", len(product.Variants), product.PriceRange())
+		fields = append(fields, variantListFields(product.Variants)...)
+	}
+
+	if w.template != nil {
+		rendered, err := w.template.Render(event)
+		if err != nil {
+			logger.Warning().Err(err).Str("id", product.ID).Msg("Failed to render message template, using the default description")
+		} else {
+			description = rendered
+		}
+	}
+
+	if w.hasColor {
+		color = w.color
+	}
+
+	return Embed{
+		Title:     product.DisplayTitle(),
+		Color:     color,
+		Url:       product.URL(),
+		Timestamp: w.timestamp(event),
+		Thumbnail: Thumbnail{
+			Url: product.Thumbnail.URL,
+		},
+		Author: Author{
+			Name:     authorName,
+			Icon_URL: w.authorIconURL,
+		},
+		Description: truncate(description, maxDescriptionLength-1) + "\n",
+		Fields:      capFields(fields),
+		Footer: Footer{
+			Text:     w.footerText,
+			Icon_url: w.authorIconURL,
+		},
+		Image: detailImage(event),
+	}
+}
+
+func listsVariants(eventType models.EventType) bool {
+	switch eventType {
+	case models.EventNew, models.EventUpcoming, models.EventAvailable, models.EventRemoved:
+		return true
+	}
+	return false
+}
+
+// timestamp returns the time shown on the event's embed: when it was
+// detected, unless discord.timestamp asks for the send time.
+func (w *Webhook) timestamp(event models.Event) time.Time {
+	if w.sendTime || event.DetectedAt.IsZero() {
+		return time.Now()
+	}
+	return event.DetectedAt
+}
+
+// buildSubCategoryEmbed announces a new subcategory, which has no product
+// details to show.
+func (w *Webhook) buildSubCategoryEmbed(event models.Event) Embed {
+	color := 1752220
+	if w.hasColor {
+		color = w.color
+	}
+
+	return Embed{
+		Title:     event.Title(),
+		Color:     color,
+		Url:       event.URL(),
+		Timestamp: w.timestamp(event),
+		Author: Author{
+			Name:     "🗂️ **New Subcategory** 🗂️",
+			Icon_URL: w.authorIconURL,
 		},
+		Description: fmt.Sprintf("A new subcategory was listed in **%s**, possibly ahead of a new product line.\n", event.Category),
+		Fields:      []Field{{Name: "Category", Value: event.Category, Inline: true}},
 		Footer: Footer{
-			Text:     "Unifi Store Monitor",
-			Icon_url: "https://tse3.mm.bing.net/th?id=OIP.RadjPrUUrLwqfVTEI5YqmwHaIV&pid=Api&P=0&w=300&h=300",
+			Text:     w.footerText,
+			Icon_url: w.authorIconURL,
 		},
 	}
+}
 
+func (w *Webhook) send(url string, embeds []Embed) error {
 	hook := Hook{
-		Username:   "Unifi Store Monitor",
-		Avatar_url: "https://tse3.mm.bing.net/th?id=OIP.RadjPrUUrLwqfVTEI5YqmwHaIV&pid=Api&P=0&w=300&h=300",
-		Embeds:     []Embed{embed},
+		Username:   w.username,
+		Avatar_url: w.avatarURL,
+		Embeds:     embeds,
 	}
 
 	payload, err := json.Marshal(hook)
@@ -105,28 +522,65 @@ func (w *Webhook) SendProduct(product models.Product) error {
 		return fmt.Errorf("failed to marshal discord payload: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", w.url, bytes.NewBuffer(payload))
+	if w.dryRun {
+		logger.Info().RawJSON("payload", payload).Msg("Dry run, skipping Discord webhook")
+		return nil
+	}
+
+	return w.retry.Do(w.Name(), func() error {
+		for attempt := 0; ; attempt++ {
+			wait, err := w.post(url, payload)
+			if err != nil || wait == 0 {
+				return err
+			}
+			if attempt >= maxRetries {
+				return fmt.Errorf("discord rate limit persisted after %d retries", maxRetries)
+			}
+
+			logger.Warning().Dur("retryAfter", wait).Msg("Discord webhook rate limited, retrying")
+			time.Sleep(wait)
+		}
+	})
+}
+
+// post sends the payload once. When Discord rate limits the request, the
+// time to wait before retrying is returned.
+func (w *Webhook) post(url string, payload []byte) (time.Duration, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
 	if err != nil {
-		return fmt.Errorf("failed to create discord request: %w", err)
+		return 0, fmt.Errorf("failed to create discord request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := w.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send discord webhook: %w", err)
+		return 0, fmt.Errorf("failed to send discord webhook: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == 429 {
-		// Rate limited, wait and retry
-		time.Sleep(5 * time.Second)
-		return w.SendProduct(product)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return rateLimitDelay(resp.Header), nil
 	}
 
 	if resp.StatusCode != 200 && resp.StatusCode != 204 {
-		return fmt.Errorf("discord webhook returned status code: %d", resp.StatusCode)
+		return 0, &customhttp.StatusError{Service: "discord webhook", StatusCode: resp.StatusCode}
 	}
 
-	return nil
+	return 0, nil
+}
+
+// rateLimitDelay reads how long Discord asked us to wait from the
+// Retry-After or X-RateLimit-Reset-After header, both given in possibly
+// fractional seconds. The wait is capped at maxRetryDelay and falls back to
+// retryDelay when neither header is usable.
+func rateLimitDelay(header http.Header) time.Duration {
+	for _, name := range []string{"Retry-After", "X-RateLimit-Reset-After"} {
+		seconds, err := strconv.ParseFloat(header.Get(name), 64)
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		return min(time.Duration(seconds*float64(time.Second)), maxRetryDelay)
+	}
+	return retryDelay
 }
@@ -0,0 +1,136 @@
+package discord
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+
+	fhttp "github.com/saucesteals/fhttp"
+)
+
+// newTestServer records the hooks posted to it and answers with the
+// statuses in turn, repeating the last one.
+func newTestServer(t *testing.T, statuses ...int) (*httptest.Server, *[]Hook) {
+	t.Helper()
+
+	var hooks []Hook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hook Hook
+		if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		hooks = append(hooks, hook)
+
+		status := statuses[min(len(hooks), len(statuses))-1]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0.01")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hooks
+}
+
+func TestSendProductWithoutVariants(t *testing.T) {
+	server, hooks := newTestServer(t, http.StatusNoContent)
+	w := New(server.URL, config.DiscordConfig{})
+
+	product := models.Product{ID: "A", Title: "Product A", Slug: "product-a"}
+	if err := w.SendProduct(product); err != nil {
+		t.Fatalf("SendProduct() failed: %v", err)
+	}
+
+	if len(*hooks) != 1 || len((*hooks)[0].Embeds) != 1 {
+		t.Fatalf("posted %v, want a single embed", *hooks)
+	}
+	fields := (*hooks)[0].Embeds[0].Fields
+	if len(fields) != 2 {
+		t.Fatalf("embed has fields %v, want variant and price", fields)
+	}
+	for _, field := range fields {
+		if field.Value != notAvailable {
+			t.Errorf("field %s = %q, want %q", field.Name, field.Value, notAvailable)
+		}
+	}
+}
+
+func TestSendEventSoldOut(t *testing.T) {
+	server, hooks := newTestServer(t, http.StatusNoContent)
+	w := New(server.URL, config.DiscordConfig{})
+
+	event := models.Event{
+		Type:     models.EventSoldOut,
+		Product:  models.Product{ID: "A", Title: "Product A", Slug: "product-a"},
+		Variants: []models.Variant{{ID: "A-v2", Status: "SoldOut"}},
+	}
+	if err := w.SendEvent(event); err != nil {
+		t.Fatalf("SendEvent() failed: %v", err)
+	}
+
+	if len(*hooks) != 1 || len((*hooks)[0].Embeds) != 1 {
+		t.Fatalf("posted %v, want a single embed", *hooks)
+	}
+	embed := (*hooks)[0].Embeds[0]
+	if embed.Author.Name != "⛔ **Sold Out** ⛔" {
+		t.Errorf("author = %q, want the sold out heading", embed.Author.Name)
+	}
+	if len(embed.Fields) == 0 || embed.Fields[0].Value != "A-v2" {
+		t.Errorf("fields = %v, want the sold out variant", embed.Fields)
+	}
+}
+
+func TestSendRetriesRateLimit(t *testing.T) {
+	server, hooks := newTestServer(t, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusNoContent)
+	w := New(server.URL, config.DiscordConfig{})
+
+	if err := w.SendProduct(models.Product{ID: "A", Title: "Product A"}); err != nil {
+		t.Fatalf("SendProduct() failed: %v", err)
+	}
+	if len(*hooks) != 3 {
+		t.Errorf("posted %d times, want 3", len(*hooks))
+	}
+}
+
+func TestSendGivesUpOnPersistentRateLimit(t *testing.T) {
+	server, hooks := newTestServer(t, http.StatusTooManyRequests)
+	w := New(server.URL, config.DiscordConfig{})
+
+	if err := w.SendProduct(models.Product{ID: "A", Title: "Product A"}); err == nil {
+		t.Fatal("SendProduct() succeeded while rate limited")
+	}
+	if len(*hooks) != maxRetries+1 {
+		t.Errorf("posted %d times, want %d", len(*hooks), maxRetries+1)
+	}
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{"retry after", map[string]string{"Retry-After": "1.5"}, 1500 * time.Millisecond},
+		{"reset after", map[string]string{"X-RateLimit-Reset-After": "2"}, 2 * time.Second},
+		{"retry after first", map[string]string{"Retry-After": "1", "X-RateLimit-Reset-After": "2"}, time.Second},
+		{"capped", map[string]string{"Retry-After": "3600"}, maxRetryDelay},
+		{"invalid", map[string]string{"Retry-After": "soon"}, retryDelay},
+		{"missing", nil, retryDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := fhttp.Header{}
+			for name, value := range tt.header {
+				header.Set(name, value)
+			}
+			if got := rateLimitDelay(header); got != tt.want {
+				t.Errorf("rateLimitDelay(%v) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+var htmlTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+{{range .}}
+<table style="margin-bottom: 24px; border: 1px solid #ddd; border-radius: 8px; padding: 12px;">
+<tr>
+{{if .Thumbnail}}<td style="padding-right: 12px;"><img src="{{.Thumbnail}}" alt="{{.Title}}" width="120"></td>{{end}}
+<td>
+<p style="margin: 0 0 4px 0; color: #666;">{{.Label}}</p>
+<h2 style="margin: 0 0 8px 0;">{{.Title}}</h2>
+<p style="margin: 0 0 8px 0;">{{.Description}}</p>
+{{if .Price}}<p style="margin: 0 0 8px 0;"><strong>{{.Price}}</strong></p>{{end}}
+<a href="{{.URL}}">{{.Link}}</a>
+</td>
+</tr>
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+type card struct {
+	Label       string
+	Title       string
+	Description string
+	Price       string
+	Thumbnail   string
+	URL         string
+	Link        string
+}
+
+// labels names each event type on its card.
+var labels = map[models.EventType]string{
+	models.EventNew:            "New product",
+	models.EventUpcoming:       "Upcoming product",
+	models.EventAvailable:      "Now available",
+	models.EventRemoved:        "Removed",
+	models.EventBackInStock:    "New variant / back in stock",
+	models.EventPriceChange:    "Price change",
+	models.EventSale:           "Sale",
+	models.EventUpdated:        "Updated",
+	models.EventInStock:        "Now in stock",
+	models.EventSoldOut:        "Sold out",
+	models.EventNewSubCategory: "New subcategory",
+}
+
+// subjects are the subjects of emails about a single event, given its
+// title.
+var subjects = map[models.EventType]string{
+	models.EventNew:            "New UniFi product: %s",
+	models.EventUpcoming:       "Upcoming UniFi product: %s",
+	models.EventAvailable:      "UniFi product now available: %s",
+	models.EventRemoved:        "UniFi product removed: %s",
+	models.EventBackInStock:    "UniFi product back in stock: %s",
+	models.EventPriceChange:    "UniFi price change: %s",
+	models.EventSale:           "UniFi sale: %s",
+	models.EventUpdated:        "UniFi product updated: %s",
+	models.EventInStock:        "UniFi product now in stock: %s",
+	models.EventSoldOut:        "UniFi product sold out: %s",
+	models.EventNewSubCategory: "New UniFi subcategory: %s",
+}
+
+type Mailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	to       []string
+	timeout  time.Duration
+	dryRun   bool
+	retry    customhttp.RetryPolicy
+}
+
+func New(host string, port int, username, password, from string, to []string) *Mailer {
+	return &Mailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+		timeout:  customhttp.DefaultTimeout,
+	}
+}
+
+func (m *Mailer) Name() string {
+	return "email"
+}
+
+// SetDryRun makes the mailer log rendered emails instead of sending them.
+func (m *Mailer) SetDryRun(enabled bool) {
+	m.dryRun = enabled
+}
+
+// SetTimeout bounds the whole SMTP exchange, from connecting to QUIT.
+func (m *Mailer) SetTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// SetRetryPolicy retries emails that failed with a network error.
+func (m *Mailer) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	m.retry = policy
+}
+
+func (m *Mailer) SendProduct(product models.Product) error {
+	return m.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (m *Mailer) SendEvent(event models.Event) error {
+	return m.SendEvents([]models.Event{event})
+}
+
+// SendEvents sends a single email with a card for each event.
+func (m *Mailer) SendEvents(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	subject := subjectFor(events)
+	message, err := m.compose(subject, events)
+	if err != nil {
+		return err
+	}
+
+	if m.dryRun {
+		logger.Info().Str("subject", subject).Str("message", string(message)).Msg("Dry run, skipping email")
+		return nil
+	}
+
+	return m.retry.Do(m.Name(), func() error {
+		return m.send(message)
+	})
+}
+
+// subjectFor names the single event an email is about, or counts the
+// events.
+func subjectFor(events []models.Event) string {
+	if len(events) == 1 {
+		return fmt.Sprintf(subjects[events[0].Type], events[0].Title())
+	}
+
+	for _, event := range events {
+		if event.Type != models.EventNew {
+			return fmt.Sprintf("%d UniFi store changes", len(events))
+		}
+	}
+	return fmt.Sprintf("%d new UniFi products", len(events))
+}
+
+// cardFor shows an event's product with the price it is alerted about.
+func cardFor(event models.Event) card {
+	product := event.Product
+	c := card{
+		Label:       labels[event.Type],
+		Title:       event.Title(),
+		Description: product.ShortDescription,
+		Price:       "N/A",
+		Thumbnail:   product.Thumbnail.URL,
+		URL:         event.URL(),
+		Link:        "View in store",
+	}
+
+	switch event.Type {
+	case models.EventNewSubCategory:
+		c.Description = fmt.Sprintf("A new subcategory was listed in %s, possibly ahead of a new product line.", event.Category)
+		c.Price = ""
+		return c
+	case models.EventPriceChange, models.EventSale:
+		if len(event.Variants) > 0 {
+			variant := event.Variants[0]
+			oldPrice := models.FormatPrice(event.OldPrices[variant.ID], variant.DisplayPrice.Currency)
+			c.Price = oldPrice + " → " + variant.Price()
+		}
+	default:
+		if len(product.Variants) > 0 {
+			c.Price = product.Variants[0].Price()
+		}
+	}
+
+	switch event.Type {
+	case models.EventNew, models.EventAvailable, models.EventBackInStock, models.EventInStock, models.EventPriceChange, models.EventSale:
+		c.Link = "Buy now"
+	}
+	return c
+}
+
+func (m *Mailer) compose(subject string, events []models.Event) ([]byte, error) {
+	cards := make([]card, 0, len(events))
+	var text strings.Builder
+	for _, event := range events {
+		c := cardFor(event)
+		cards = append(cards, c)
+
+		fmt.Fprintf(&text, "%s: %s\n%s\n", c.Label, c.Title, c.Description)
+		if c.Price != "" {
+			fmt.Fprintf(&text, "Price: %s\n", c.Price)
+		}
+		fmt.Fprintf(&text, "%s\n\n", c.URL)
+	}
+
+	var html bytes.Buffer
+	if err := htmlTemplate.Execute(&html, cards); err != nil {
+		return nil, fmt.Errorf("failed to render email: %w", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", []byte(text.String())},
+		{"text/html; charset=UTF-8", html.Bytes()},
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create email part: %w", err)
+		}
+		if _, err := w.Write(part.content); err != nil {
+			return nil, fmt.Errorf("failed to write email part: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish email body: %w", err)
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", m.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func (m *Mailer) send(message []byte) error {
+	addr := m.host + ":" + strconv.Itoa(m.port)
+
+	conn, err := net.DialTimeout("tcp", addr, m.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(m.timeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set smtp deadline: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range m.to {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start email data: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,100 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"all-unifi-monitor/internal/models"
+)
+
+func testEvent(eventType models.EventType) models.Event {
+	product := models.Product{
+		ID:    "A",
+		Title: "Product A",
+		Slug:  "product-a",
+		Variants: []models.Variant{
+			{ID: "A-v1", DisplayPrice: models.DisplayPrice{Amount: 8000, Currency: "USD"}},
+		},
+	}
+	return models.Event{
+		Type:      eventType,
+		Product:   product,
+		Variants:  product.Variants,
+		OldPrices: map[string]int{"A-v1": 10000},
+	}
+}
+
+func TestSubjectFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []models.Event
+		want   string
+	}{
+		{"new product", []models.Event{testEvent(models.EventNew)}, "New UniFi product: Product A"},
+		{"removal", []models.Event{testEvent(models.EventRemoved)}, "UniFi product removed: Product A"},
+		{"price change", []models.Event{testEvent(models.EventPriceChange)}, "UniFi price change: Product A"},
+		{"new products", []models.Event{testEvent(models.EventNew), testEvent(models.EventNew)}, "2 new UniFi products"},
+		{"mixed", []models.Event{testEvent(models.EventNew), testEvent(models.EventSale)}, "2 UniFi store changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subjectFor(tt.events); got != tt.want {
+				t.Errorf("subjectFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCardFor(t *testing.T) {
+	tests := []struct {
+		eventType models.EventType
+		label     string
+		price     string
+		link      string
+	}{
+		{models.EventNew, "New product", "$80.00", "Buy now"},
+		{models.EventRemoved, "Removed", "$80.00", "View in store"},
+		{models.EventPriceChange, "Price change", "$100.00 → $80.00", "Buy now"},
+		{models.EventSale, "Sale", "$100.00 → $80.00", "Buy now"},
+		{models.EventBackInStock, "New variant / back in stock", "$80.00", "Buy now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.eventType), func(t *testing.T) {
+			c := cardFor(testEvent(tt.eventType))
+			if c.Label != tt.label || c.Price != tt.price || c.Link != tt.link {
+				t.Errorf("cardFor() = %+v, want label %q, price %q and link %q", c, tt.label, tt.price, tt.link)
+			}
+		})
+	}
+}
+
+func TestComposeSubCategory(t *testing.T) {
+	event := models.Event{Type: models.EventNewSubCategory, Category: "all-wifi", SubCategory: "Wi-Fi 7"}
+	m := New("smtp.example.com", 587, "", "", "monitor@example.com", []string{"me@example.com"})
+
+	message, err := m.compose(subjectFor([]models.Event{event}), []models.Event{event})
+	if err != nil {
+		t.Fatalf("compose() failed: %v", err)
+	}
+	for _, want := range []string{"Subject: New UniFi subcategory: Wi-Fi 7", "listed in all-wifi"} {
+		if !strings.Contains(string(message), want) {
+			t.Errorf("email is missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"<img", "Buy now", "Price:"} {
+		if strings.Contains(string(message), unwanted) {
+			t.Errorf("subcategory email contains %q", unwanted)
+		}
+	}
+}
+
+func TestSendEventDryRun(t *testing.T) {
+	m := New("smtp.example.com", 587, "", "", "monitor@example.com", []string{"me@example.com"})
+	m.SetDryRun(true)
+
+	if err := m.SendEvent(testEvent(models.EventRemoved)); err != nil {
+		t.Errorf("SendEvent() failed: %v", err)
+	}
+}
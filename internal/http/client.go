@@ -2,46 +2,185 @@ package http
 
 import (
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	http "github.com/saucesteals/fhttp"
 	"github.com/saucesteals/mimic"
+
+	"all-unifi-monitor/pkg/logger"
 )
 
+// fallbackChromeVersion is mimicked when the latest Chrome release can't be
+// looked up, so the monitor still starts without access to Google's version
+// history.
+const fallbackChromeVersion = "120.0.6099.109"
+
 var (
-	latestVersion = mimic.MustGetLatestVersion(mimic.PlatformWindows)
+	latestVersion = lookupChromeVersion()
 )
 
+func lookupChromeVersion() string {
+	version, err := mimic.GetLatestVersion(mimic.PlatformWindows)
+	if err != nil {
+		logger.Warning().Err(err).Str("version", fallbackChromeVersion).Msg("Failed to look up the latest Chrome version, using a pinned one")
+		return fallbackChromeVersion
+	}
+	return version
+}
+
+// DefaultTimeout bounds every request so a hung connection can't stall a
+// category sweep indefinitely. It can be changed per client with SetTimeout.
+const DefaultTimeout = 10 * time.Second
+
+const defaultAcceptLanguage = "en,en_US;q=0.9"
+
+// defaultHeaderOrder is the order Chrome sends its headers in. The TLS and
+// HTTP/2 fingerprint from mimic only looks like a real browser when the
+// headers match it, so reordering them may get requests blocked.
+var defaultHeaderOrder = []string{
+	"sec-ch-ua", "rtt", "sec-ch-ua-mobile",
+	"user-agent", "accept", "x-requested-with",
+	"downlink", "ect", "sec-ch-ua-platform",
+	"sec-fetch-site", "sec-fetch-mode", "sec-fetch-dest",
+	"accept-encoding", "accept-language",
+}
+
+// Options overrides the browser-like headers sent with every request. Empty
+// fields keep the defaults. Headers that replace a default keep its
+// position; new headers are sent after the defaults, in sorted order.
+type Options struct {
+	UserAgent      string
+	AcceptLanguage string
+	Headers        map[string]string
+}
+
 type Client struct {
+	// mutex guards the fingerprint, which RefreshFingerprint replaces while
+	// requests may be in flight: the embedded client, m and ua
+	mutex sync.RWMutex
 	*http.Client
 	ua string
-	m  *mimic.ClientSpec
+	// customUA is set when the user agent comes from Options, so a
+	// fingerprint refresh keeps it
+	customUA       bool
+	acceptLanguage string
+	headers        http.Header
+	headerOrder    []string
+	m              *mimic.ClientSpec
+	proxies        *proxyPool
+	limiter        *RateLimiter
+	// socks5Proxy is the proxy set by SetSOCKS5Proxy, if any
+	socks5Proxy *url.URL
 }
 
 func NewClient() *Client {
+	return NewClientWithOptions(Options{})
+}
+
+// NewClientWithOptions returns a client whose default headers are changed
+// by opts.
+func NewClientWithOptions(opts Options) *Client {
 	m, _ := mimic.Chromium(mimic.BrandChrome, latestVersion)
 
-	ua := fmt.Sprintf("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%s Safari/537.36", m.Version())
+	ua := chromeUserAgent(m.Version())
+	if opts.UserAgent != "" {
+		ua = opts.UserAgent
+	}
+
+	acceptLanguage := defaultAcceptLanguage
+	if opts.AcceptLanguage != "" {
+		acceptLanguage = opts.AcceptLanguage
+	}
+
+	headers := make(http.Header, len(opts.Headers))
+	headerOrder := slices.Clone(defaultHeaderOrder)
+	var extra []string
+	for key, value := range opts.Headers {
+		key = strings.ToLower(key)
+		headers[key] = []string{value}
+		if !slices.Contains(defaultHeaderOrder, key) {
+			extra = append(extra, key)
+		}
+	}
+	slices.Sort(extra)
+	headerOrder = append(headerOrder, extra...)
 
 	client := &http.Client{
 		Transport: m.ConfigureTransport(&http.Transport{
-			Proxy: http.ProxyFromEnvironment,
+			Proxy: proxyFromContext,
 		}),
+		Timeout: DefaultTimeout,
 	}
 
 	return &Client{
-		Client: client,
-		ua:     ua,
-		m:      m,
+		Client:         client,
+		ua:             ua,
+		customUA:       opts.UserAgent != "",
+		acceptLanguage: acceptLanguage,
+		headers:        headers,
+		headerOrder:    headerOrder,
+		m:              m,
+	}
+}
+
+// SetTimeout changes how long a request may take, including reading the
+// response body.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.Client.Timeout = timeout
+}
+
+// SetRateLimiter makes every request wait for the limiter, which may be
+// shared with other clients.
+func (c *Client) SetRateLimiter(limiter *RateLimiter) {
+	c.limiter = limiter
+}
+
+// NewClientWithProxies returns a client that rotates through the given
+// proxies for each request. A proxy that fails is skipped for the cooldown
+// period. Without proxies it behaves like NewClientWithOptions.
+func NewClientWithProxies(proxies []string, cooldown time.Duration, opts Options) (*Client, error) {
+	c := NewClientWithOptions(opts)
+	if len(proxies) == 0 {
+		return c, nil
+	}
+
+	pool, err := newProxyPool(proxies, cooldown)
+	if err != nil {
+		return nil, err
 	}
+	c.proxies = pool
+
+	return c, nil
 }
 
+// Do sends the request with browser-like headers. Headers from the client's
+// Options, then headers already set on the request, override the defaults
+// of the same name.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mutex.RLock()
+	client, m, ua := c.Client, c.m, c.ua
+	c.mutex.RUnlock()
+
+	custom := req.Header
 
 	req.Header = http.Header{
-		"sec-ch-ua":          {c.m.ClientHintUA()},
+		"sec-ch-ua":          {m.ClientHintUA()},
 		"rtt":                {"50"},
 		"sec-ch-ua-mobile":   {"?0"},
-		"user-agent":         {c.ua},
+		"user-agent":         {ua},
 		"accept":             {"text/html,*/*"},
 		"x-requested-with":   {"XMLHttpRequest"},
 		"downlink":           {"3.9"},
@@ -51,16 +190,36 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		"sec-fetch-mode":     {"cors"},
 		"sec-fetch-dest":     {"empty"},
 		"accept-encoding":    {"gzip, deflate, br"},
-		"accept-language":    {"en,en_US;q=0.9"},
-		http.HeaderOrderKey: {
-			"sec-ch-ua", "rtt", "sec-ch-ua-mobile",
-			"user-agent", "accept", "x-requested-with",
-			"downlink", "ect", "sec-ch-ua-platform",
-			"sec-fetch-site", "sec-fetch-mode", "sec-fetch-dest",
-			"accept-encoding", "accept-language",
-		},
-		http.PHeaderOrderKey: c.m.PseudoHeaderOrder(),
+		"accept-language":    {c.acceptLanguage},
+		http.HeaderOrderKey:  c.headerOrder,
+		http.PHeaderOrderKey: m.PseudoHeaderOrder(),
+	}
+
+	// Keys are lowercased so overrides keep their position in the header order
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	for key, values := range custom {
+		req.Header[strings.ToLower(key)] = values
+	}
+
+	if c.proxies == nil {
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		return decodeBody(resp), nil
+	}
+
+	proxyURL := c.proxies.pick()
+	resp, err := client.Do(withProxy(req, proxyURL))
+	if err != nil {
+		c.proxies.markUnhealthy(proxyURL, err)
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		c.proxies.markUnhealthy(proxyURL, fmt.Errorf("proxy returned status code: %d", resp.StatusCode))
 	}
 
-	return c.Client.Do(req)
+	return decodeBody(resp), nil
 }
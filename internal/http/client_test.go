@@ -0,0 +1,153 @@
+package http
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// captureRequest serves a single request on a local listener and returns
+// its header lines in the order they were sent.
+func captureRequest(t *testing.T, client *Client, header http.Header) []string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			lines <- nil
+			return
+		}
+		defer conn.Close()
+
+		var received []string
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			line = strings.TrimRight(line, "\r\n")
+			if err != nil || line == "" {
+				break
+			}
+			received = append(received, line)
+		}
+		conn.Write([]byte("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n"))
+		lines <- received
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+"/", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// The request line comes first
+	return (<-lines)[1:]
+}
+
+// headerValues maps the lowercased names of header lines to their values and
+// returns the names in the order they were sent.
+func headerValues(lines []string) (map[string]string, []string) {
+	values := make(map[string]string, len(lines))
+	var order []string
+	for _, line := range lines {
+		name, value, _ := strings.Cut(line, ":")
+		name = strings.ToLower(name)
+		values[name] = strings.TrimSpace(value)
+		order = append(order, name)
+	}
+	return values, order
+}
+
+func TestClientSendsFingerprintHeaders(t *testing.T) {
+	values, order := headerValues(captureRequest(t, NewClient(), nil))
+
+	if ua := values["user-agent"]; !strings.Contains(ua, "Chrome/") || !strings.Contains(ua, "Windows NT") {
+		t.Errorf("user-agent = %q, want a Chrome on Windows user agent", ua)
+	}
+	if hints := values["sec-ch-ua"]; !strings.Contains(hints, "Chrome") {
+		t.Errorf("sec-ch-ua = %q, want the Chrome client hints", hints)
+	}
+	for name, want := range map[string]string{
+		"sec-ch-ua-mobile":   "?0",
+		"sec-ch-ua-platform": `"Windows"`,
+		"sec-fetch-site":     "same-origin",
+		"sec-fetch-mode":     "cors",
+		"sec-fetch-dest":     "empty",
+		"accept-encoding":    "gzip, deflate, br",
+		"accept-language":    defaultAcceptLanguage,
+	} {
+		if values[name] != want {
+			t.Errorf("%s = %q, want %q", name, values[name], want)
+		}
+	}
+
+	// The fingerprint only passes for a browser when the headers come in
+	// Chrome's order
+	position := make(map[string]int, len(order))
+	for i, name := range order {
+		position[name] = i
+	}
+	last := -1
+	for _, name := range defaultHeaderOrder {
+		i, ok := position[name]
+		if !ok {
+			t.Errorf("header %s was not sent", name)
+			continue
+		}
+		if i < last {
+			t.Errorf("header %s was sent out of order: %v", name, order)
+		}
+		last = i
+	}
+}
+
+func TestClientHeaderOverrides(t *testing.T) {
+	client := NewClientWithOptions(Options{
+		UserAgent:      "monitor-test",
+		AcceptLanguage: "de-DE",
+		Headers:        map[string]string{"X-Extra": "1", "Accept": "application/json"},
+	})
+
+	values, order := headerValues(captureRequest(t, client, http.Header{"Referer": {"https://store.ui.com/"}}))
+
+	for name, want := range map[string]string{
+		"user-agent":      "monitor-test",
+		"accept-language": "de-DE",
+		"accept":          "application/json",
+		"x-extra":         "1",
+		"referer":         "https://store.ui.com/",
+	} {
+		if values[name] != want {
+			t.Errorf("%s = %q, want %q", name, values[name], want)
+		}
+	}
+	if hints := values["sec-ch-ua"]; !strings.Contains(hints, "Chrome") {
+		t.Errorf("sec-ch-ua = %q, want the Chrome client hints with a custom user agent", hints)
+	}
+
+	// An override keeps the position of the default it replaces
+	position := make(map[string]int, len(order))
+	for i, name := range order {
+		position[name] = i
+	}
+	if position["accept"] > position["x-requested-with"] {
+		t.Errorf("accept moved after x-requested-with: %v", order)
+	}
+}
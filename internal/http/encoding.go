@@ -0,0 +1,23 @@
+package http
+
+import (
+	http "github.com/saucesteals/fhttp"
+)
+
+// decodeBody makes resp.Body return the decoded content. The transport only
+// decompresses bodies itself when it chose the Accept-Encoding header, but Do
+// advertises gzip, deflate and br explicitly, so HTTP/1 responses arrive
+// still encoded. HTTP/2 responses are already decoded by the transport.
+// Other encodings are left untouched.
+func decodeBody(resp *http.Response) *http.Response {
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip", "deflate", "br":
+		if !resp.Uncompressed {
+			resp.Body = http.DecompressBody(resp)
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+	}
+
+	return resp
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+func TestDecodeBody(t *testing.T) {
+	want, err := os.ReadFile("testdata/body.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	tests := []struct {
+		encoding string
+		fixture  string
+	}{
+		{"gzip", "testdata/body.json.gz"},
+		{"br", "testdata/body.json.br"},
+		{"", "testdata/body.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(tt.fixture)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+
+			server := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			resp, err := NewClient().Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("body = %q, want %q", got, want)
+			}
+			if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+				t.Errorf("Content-Encoding = %q after decoding, want it removed", encoding)
+			}
+		})
+	}
+}
+
+func TestDecodeBodyKeepsUnknownEncoding(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"zstd"}},
+		Body:   io.NopCloser(bytes.NewReader([]byte("encoded"))),
+	}
+
+	resp = decodeBody(resp)
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "zstd" {
+		t.Errorf("Content-Encoding = %q, want zstd kept", encoding)
+	}
+	if got, _ := io.ReadAll(resp.Body); string(got) != "encoded" {
+		t.Errorf("body = %q, want it untouched", got)
+	}
+}
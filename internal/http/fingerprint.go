@@ -0,0 +1,59 @@
+package http
+
+import (
+	"fmt"
+
+	http "github.com/saucesteals/fhttp"
+	"github.com/saucesteals/mimic"
+
+	"all-unifi-monitor/pkg/logger"
+)
+
+func chromeUserAgent(version string) string {
+	return fmt.Sprintf("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%s Safari/537.36", version)
+}
+
+// RefreshFingerprint replaces the client's browser fingerprint with one of
+// the latest Chrome release and a fresh transport, dropping every open
+// connection. The version pinned at startup drifts from real Chrome over
+// time, which anti-bot checks may notice. When the latest version can't be
+// looked up the current one is kept, but the transport is still replaced.
+// Proxies, the rate limiter and header overrides are kept. The previous and
+// new Chrome versions are returned.
+func (c *Client) RefreshFingerprint() (string, string, error) {
+	c.mutex.RLock()
+	previous := c.m.Version()
+	c.mutex.RUnlock()
+
+	version, err := mimic.GetLatestVersion(mimic.PlatformWindows)
+	if err != nil {
+		logger.Warning().Err(err).Msg("Failed to look up the latest Chrome version, keeping the current one")
+		version = previous
+	}
+
+	m, err := mimic.Chromium(mimic.BrandChrome, version)
+	if err != nil {
+		return previous, version, fmt.Errorf("failed to create fingerprint for Chrome %s: %w", version, err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	client := &http.Client{
+		Transport: m.ConfigureTransport(&http.Transport{
+			Proxy: proxyFromContext,
+		}),
+		Timeout: c.Client.Timeout,
+	}
+	if c.socks5Proxy != nil {
+		if err := useSOCKS5(client, c.socks5Proxy); err != nil {
+			return previous, version, err
+		}
+	}
+
+	c.Client, c.m = client, m
+	if !c.customUA {
+		c.ua = chromeUserAgent(version)
+	}
+	return previous, version, nil
+}
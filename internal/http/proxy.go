@@ -0,0 +1,107 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	http "github.com/saucesteals/fhttp"
+
+	"all-unifi-monitor/pkg/logger"
+)
+
+type proxyContextKey struct{}
+
+// proxyPool hands out proxies round-robin, skipping proxies that recently
+// failed until their cooldown expires.
+type proxyPool struct {
+	mu             sync.Mutex
+	proxies        []*url.URL
+	unhealthyUntil map[string]time.Time
+	cooldown       time.Duration
+	next           int
+}
+
+func newProxyPool(proxies []string, cooldown time.Duration) (*proxyPool, error) {
+	pool := &proxyPool{
+		unhealthyUntil: make(map[string]time.Time),
+		cooldown:       cooldown,
+	}
+
+	for _, raw := range proxies {
+		proxyURL, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", raw, proxyURL.Scheme)
+		}
+		pool.proxies = append(pool.proxies, proxyURL)
+	}
+
+	return pool, nil
+}
+
+// pick returns the next healthy proxy. When every proxy is cooling down the
+// next one in line is used anyway rather than failing the request.
+func (p *proxyPool) pick() *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(p.proxies); i++ {
+		proxyURL := p.proxies[(p.next+i)%len(p.proxies)]
+		if now.Before(p.unhealthyUntil[proxyURL.String()]) {
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.proxies)
+		return proxyURL
+	}
+
+	proxyURL := p.proxies[p.next]
+	p.next = (p.next + 1) % len(p.proxies)
+	logger.Warning().Str("proxy", proxyURL.Redacted()).Msg("All proxies are cooling down, using next proxy anyway")
+	return proxyURL
+}
+
+func (p *proxyPool) markUnhealthy(proxyURL *url.URL, err error) {
+	p.mu.Lock()
+	p.unhealthyUntil[proxyURL.String()] = time.Now().Add(p.cooldown)
+	p.mu.Unlock()
+
+	logger.Warning().
+		Err(err).
+		Str("proxy", proxyURL.Redacted()).
+		Dur("cooldown", p.cooldown).
+		Msg("Proxy failed, skipping it until the cooldown expires")
+}
+
+// proxyFromContext is used as the transport's Proxy func. It returns the
+// proxy chosen for the request in Client.Do.
+func proxyFromContext(req *http.Request) (*url.URL, error) {
+	if proxyURL, ok := req.Context().Value(proxyContextKey{}).(*url.URL); ok {
+		return proxyURL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+func withProxy(req *http.Request, proxyURL *url.URL) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), proxyContextKey{}, proxyURL))
+}
+
+// ReportBlocked marks the proxy that served resp as unhealthy, so following
+// requests rotate to another proxy. It is meant for responses that look
+// successful but were blocked, such as a challenge page with a 200 status.
+// Without proxies it does nothing.
+func (c *Client) ReportBlocked(resp *http.Response, reason error) {
+	if c.proxies == nil || resp.Request == nil {
+		return
+	}
+	if proxyURL, ok := resp.Request.Context().Value(proxyContextKey{}).(*url.URL); ok {
+		c.proxies.markUnhealthy(proxyURL, reason)
+	}
+}
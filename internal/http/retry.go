@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"all-unifi-monitor/pkg/logger"
+)
+
+// maxRetryBackoff caps the wait between two attempts of a notification.
+const maxRetryBackoff = time.Minute
+
+// StatusError is returned by notifiers when a service responds with an
+// unexpected HTTP status code.
+type StatusError struct {
+	Service    string
+	StatusCode int
+	// Detail is the error message from the response body, if any
+	Detail string
+}
+
+func (e *StatusError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%s returned status code %d: %s", e.Service, e.StatusCode, e.Detail)
+	}
+	return fmt.Sprintf("%s returned status code: %d", e.Service, e.StatusCode)
+}
+
+// IsTransient reports whether a failed notification may succeed when sent
+// again. Network errors and 5xx responses are transient; other status codes
+// and encoding errors are not.
+func IsTransient(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return IsNetworkError(err)
+}
+
+// IsNetworkError reports whether err is a timeout, a reset connection, a
+// response cut short or another network failure, which are worth retrying
+// whatever the request was.
+func IsNetworkError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RetryPolicy controls how a notification that failed with a transient
+// error is retried. The zero value sends it once.
+type RetryPolicy struct {
+	// MaxAttempts is how many times the notification is sent at most,
+	// including the first attempt
+	MaxAttempts int
+	// Backoff is the wait after the first failure. It doubles after every
+	// further failure, up to a minute.
+	Backoff time.Duration
+}
+
+// Do calls send until it succeeds, fails with an error that isn't
+// transient, or the attempts run out. The last error is returned.
+func (p RetryPolicy) Do(service string, send func() error) error {
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= p.MaxAttempts || !IsTransient(err) {
+			return err
+		}
+
+		logger.Warning().
+			Err(err).
+			Str("notifier", service).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("Notification failed, retrying")
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &StatusError{Service: "test", StatusCode: 502}, true},
+		{"client error", &StatusError{Service: "test", StatusCode: 400}, false},
+		{"rate limited", &StatusError{Service: "test", StatusCode: 429}, false},
+		{"timeout", fmt.Errorf("failed to send: %w", context.DeadlineExceeded), true},
+		{"cut short", fmt.Errorf("failed to read: %w", io.ErrUnexpectedEOF), true},
+		{"encoding", errors.New("failed to marshal"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsTransient(test.err); got != test.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	transient := &StatusError{Service: "test", StatusCode: 503}
+	permanent := &StatusError{Service: "test", StatusCode: 404}
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{"success", RetryPolicy{MaxAttempts: 3}, []error{nil}, 1, nil},
+		{"recovers", RetryPolicy{MaxAttempts: 3}, []error{transient, transient, nil}, 3, nil},
+		{"gives up", RetryPolicy{MaxAttempts: 3}, []error{transient, transient, transient, nil}, 3, transient},
+		{"permanent", RetryPolicy{MaxAttempts: 3}, []error{permanent, nil}, 1, permanent},
+		{"zero value", RetryPolicy{}, []error{transient, nil}, 1, transient},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			err := test.policy.Do("test", func() error {
+				err := test.errs[attempts]
+				attempts++
+				return err
+			})
+			if attempts != test.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, test.wantAttempts)
+			}
+			if err != test.wantErr {
+				t.Errorf("err = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package matrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+const (
+	maxRetries = 3
+	retryDelay = 5 * time.Second
+
+	// Thumbnails larger than this are linked instead of uploaded
+	maxImageSize = 5 << 20
+)
+
+// message is the content of an m.room.message event.
+type message struct {
+	MsgType       string     `json:"msgtype"`
+	Body          string     `json:"body"`
+	Format        string     `json:"format,omitempty"`
+	FormattedBody string     `json:"formatted_body,omitempty"`
+	URL           string     `json:"url,omitempty"`
+	Info          *imageInfo `json:"info,omitempty"`
+}
+
+type imageInfo struct {
+	MimeType string `json:"mimetype"`
+	Size     int    `json:"size"`
+}
+
+type apiError struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMs int    `json:"retry_after_ms"`
+}
+
+// Room posts alerts to a Matrix room through the client-server API.
+type Room struct {
+	homeserver  string
+	accessToken string
+	roomID      string
+	httpClient  *customhttp.Client
+	dryRun      bool
+	retry       customhttp.RetryPolicy
+	txnCounter  atomic.Uint64
+}
+
+func New(homeserver, accessToken, roomID string) *Room {
+	return &Room{
+		homeserver:  strings.TrimRight(homeserver, "/"),
+		accessToken: accessToken,
+		roomID:      roomID,
+		httpClient:  customhttp.NewClient(),
+	}
+}
+
+func (r *Room) Name() string {
+	return "matrix"
+}
+
+// SetDryRun makes the room log rendered messages instead of sending them.
+func (r *Room) SetDryRun(enabled bool) {
+	r.dryRun = enabled
+}
+
+// SetTimeout changes how long a request to the homeserver may take.
+func (r *Room) SetTimeout(timeout time.Duration) {
+	r.httpClient.SetTimeout(timeout)
+}
+
+// SetRetryPolicy retries requests that failed with a network error or a 5xx
+// response.
+func (r *Room) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	r.retry = policy
+}
+
+func (r *Room) SendProduct(product models.Product) error {
+	return r.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+// SendEvent posts the product thumbnail as an m.image, followed by a text
+// message with the details and link. When the thumbnail can't be uploaded,
+// only the text message is sent.
+func (r *Room) SendEvent(event models.Event) error {
+	text := textMessage(event)
+
+	if r.dryRun {
+		logger.Info().
+			Str("room", r.roomID).
+			Str("image", event.Product.Thumbnail.URL).
+			Str("body", text.Body).
+			Msg("Dry run, skipping Matrix message")
+		return nil
+	}
+
+	if event.Product.Thumbnail.URL != "" {
+		image, err := r.uploadImage(event.Product.Thumbnail.URL)
+		if err == nil {
+			err = r.send(image)
+		}
+		if err != nil {
+			logger.Warning().Err(err).Str("id", event.Product.ID).Msg("Failed to send Matrix image, sending text only")
+		}
+	}
+
+	return r.send(text)
+}
+
+func textMessage(event models.Event) message {
+	product := event.Product
+
+	heading := "🎉 New Product Alert!"
+	switch event.Type {
+	case models.EventUpcoming:
+		heading = "🔜 Upcoming Product"
+	case models.EventAvailable:
+		heading = "✅ Now Available"
+	case models.EventRemoved:
+		heading = "🚫 Product Removed"
+	case models.EventBackInStock:
+		heading = "🔁 New Variant / Back in Stock"
+	case models.EventPriceChange:
+		heading = "💲 Price Change"
+	case models.EventSale:
+		heading = "🏷️ Sale"
+	case models.EventUpdated:
+		heading = "✏️ Product Updated"
+	case models.EventInStock:
+		heading = "🚀 Now In Stock"
+	case models.EventSoldOut:
+		heading = "⛔ Sold Out"
+	case models.EventNewSubCategory:
+		heading = "🗂️ New Subcategory in " + event.Category
+	}
+
+	price := ""
+	if len(product.Variants) > 0 {
+		price = "Price: " + product.Variants[0].Price()
+	}
+
+	lines := []string{heading, event.Title()}
+	htmlLines := []string{
+		"<strong>" + html.EscapeString(heading) + "</strong>",
+		fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(event.URL()), html.EscapeString(event.Title())),
+	}
+	if price != "" {
+		lines = append(lines, price)
+		htmlLines = append(htmlLines, html.EscapeString(price))
+	}
+	lines = append(lines, event.URL())
+
+	return message{
+		MsgType:       "m.text",
+		Body:          strings.Join(lines, "\n"),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: strings.Join(htmlLines, "<br>"),
+	}
+}
+
+// uploadImage downloads the thumbnail and uploads it to the homeserver's
+// media repository, returning an m.image message that references it.
+func (r *Room) uploadImage(imageURL string) (message, error) {
+	req, err := http.NewRequest(http.MethodGet, imageURL, nil)
+	if err != nil {
+		return message{}, fmt.Errorf("failed to create thumbnail request: %w", err)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return message{}, fmt.Errorf("failed to download thumbnail: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return message{}, fmt.Errorf("thumbnail returned status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return message{}, fmt.Errorf("failed to read thumbnail: %w", err)
+	}
+	if len(data) > maxImageSize {
+		return message{}, fmt.Errorf("thumbnail is larger than %d bytes", maxImageSize)
+	}
+
+	mimeType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+
+	filename := path.Base(strings.SplitN(imageURL, "?", 2)[0])
+	endpoint := fmt.Sprintf("%s/_matrix/media/v3/upload?filename=%s", r.homeserver, url.QueryEscape(filename))
+
+	var uploaded struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := r.do(http.MethodPost, endpoint, mimeType, data, &uploaded); err != nil {
+		return message{}, fmt.Errorf("failed to upload thumbnail: %w", err)
+	}
+
+	return message{
+		MsgType: "m.image",
+		Body:    filename,
+		URL:     uploaded.ContentURI,
+		Info:    &imageInfo{MimeType: mimeType, Size: len(data)},
+	}, nil
+}
+
+// send posts a message to the room. Each message gets its own transaction
+// ID so the homeserver can deduplicate retried requests.
+func (r *Room) send(msg message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal matrix message: %w", err)
+	}
+
+	txnID := fmt.Sprintf("%d.%d", time.Now().UnixNano(), r.txnCounter.Add(1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		r.homeserver, url.PathEscape(r.roomID), txnID)
+
+	return r.do(http.MethodPut, endpoint, "application/json", payload, nil)
+}
+
+// do sends an authenticated request, retrying when the homeserver rate
+// limits it or fails transiently, and decodes the response into result when
+// it's not nil.
+func (r *Room) do(method, endpoint, contentType string, body []byte, result any) error {
+	return r.retry.Do(r.Name(), func() error {
+		for attempt := 0; ; attempt++ {
+			retryAfter, err := r.request(method, endpoint, contentType, body, result)
+			if err != nil || retryAfter == 0 {
+				return err
+			}
+			if attempt >= maxRetries {
+				return fmt.Errorf("matrix rate limit persisted after %d retries", maxRetries)
+			}
+
+			logger.Warning().Dur("retryAfter", retryAfter).Msg("Matrix homeserver rate limited, retrying")
+			time.Sleep(retryAfter)
+		}
+	})
+}
+
+// request performs a single call. A non-zero duration is returned when the
+// homeserver rate limited the request and asked us to retry later.
+func (r *Room) request(method, endpoint, contentType string, body []byte, result any) (time.Duration, error) {
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create matrix request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+r.accessToken)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send matrix request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if apiErr.RetryAfterMs > 0 {
+				return time.Duration(apiErr.RetryAfterMs) * time.Millisecond, nil
+			}
+			return retryDelay, nil
+		}
+		return 0, &customhttp.StatusError{Service: "matrix", StatusCode: resp.StatusCode, Detail: strings.TrimSpace(apiErr.ErrCode + " " + apiErr.Error)}
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return 0, fmt.Errorf("failed to decode matrix response: %w", err)
+		}
+	}
+
+	return 0, nil
+}
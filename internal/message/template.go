@@ -0,0 +1,123 @@
+// Package message renders the user-defined message_template that notifiers
+// with plain-text messages use instead of their built-in text.
+package message
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// Data is what the template is executed with.
+type Data struct {
+	EventType models.EventType
+	Product   models.Product
+	// Title is the product title shown in alerts, prefixed with the
+	// storefront for products outside the main store
+	Title    string
+	URL      string
+	Category string
+	// Region is the store region of the product, such as us or uk
+	Region string
+	// Variants are the variants the event is about, if any
+	Variants []models.Variant
+	// Price is the current and OldPrice the previous price of the first
+	// variant that changed, in the currency's minor unit. OldPrice is 0
+	// unless the event is a price change or sale.
+	Price    int
+	OldPrice int
+	Currency string
+}
+
+// funcs are the helpers available to templates.
+var funcs = template.FuncMap{
+	"formatPrice": models.FormatPrice,
+	"upper":       strings.ToUpper,
+	"lower":       strings.ToLower,
+	"join":        strings.Join,
+}
+
+// Template is a parsed message_template.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses the template text. Nil is returned for an empty text. The
+// template is tried on a sample event so that references to fields that
+// don't exist are reported here rather than when the first alert is sent.
+func Parse(text string) (*Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("message_template").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message_template: %w", err)
+	}
+
+	t := &Template{tmpl: tmpl}
+	if _, err := t.Render(sampleEvent); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Render executes the template for the event.
+func (t *Template) Render(event models.Event) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, newData(event)); err != nil {
+		return "", fmt.Errorf("failed to render message_template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func newData(event models.Event) Data {
+	data := Data{
+		EventType: event.Type,
+		Product:   event.Product,
+		Title:     event.Title(),
+		URL:       event.URL(),
+		Category:  event.Category,
+		Region:    event.Product.Region(),
+		Variants:  event.Variants,
+	}
+
+	variants := event.Variants
+	if len(variants) == 0 {
+		variants = event.Product.Variants
+	}
+	if len(variants) > 0 {
+		data.Price = variants[0].DisplayPrice.Amount
+		data.Currency = variants[0].DisplayPrice.Currency
+	}
+	for _, variant := range event.Variants {
+		if oldPrice, ok := event.OldPrices[variant.ID]; ok {
+			data.Price = variant.DisplayPrice.Amount
+			data.OldPrice = oldPrice
+			data.Currency = variant.DisplayPrice.Currency
+			break
+		}
+	}
+	return data
+}
+
+var sampleEvent = models.Event{
+	Type: models.EventPriceChange,
+	Product: models.Product{
+		ID:    "sample",
+		Title: "Sample Product",
+		Slug:  "sample-product",
+		Variants: []models.Variant{{
+			ID:           "sample-variant",
+			DisplayPrice: models.DisplayPrice{Amount: 9900, Currency: "USD"},
+		}},
+	},
+	Variants: []models.Variant{{
+		ID:           "sample-variant",
+		DisplayPrice: models.DisplayPrice{Amount: 9900, Currency: "USD"},
+	}},
+	OldPrices: map[string]int{"sample-variant": 12900},
+}
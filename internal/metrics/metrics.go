@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+var fetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "unifi_monitor",
+	Name:      "fetch_duration_seconds",
+	Help:      "Duration of requests to the Unifi store, by category and outcome.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"category", "outcome"})
+
+func init() {
+	prometheus.MustRegister(fetchDuration)
+}
+
+// ObserveFetch records how long a store request took. The build ID lookup
+// is recorded under the "build_id" category.
+func ObserveFetch(category string, start time.Time, err error) {
+	fetchDuration.WithLabelValues(category, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+func outcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return OutcomeTimeout
+	}
+	return OutcomeError
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// UnmarshalJSON accepts the amount as an integer in the currency's minor
+// unit, as the store normally sends it, or as a float or numeric string,
+// as some regional and promotional listings do. A float, or a string with a
+// decimal or thousands separator, is taken to be in the major unit, such as
+// dollars, and is converted to the minor unit. Currency symbols and spaces
+// are ignored in strings.
+func (p *DisplayPrice) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	amount, err := parseAmount(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	p.Amount, p.Currency = amount, raw.Currency
+	return nil
+}
+
+// parseAmount converts a JSON amount to the currency's minor unit. A
+// missing or null amount is zero.
+func parseAmount(data json.RawMessage, currency string) (int, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+
+	value := string(data)
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return 0, err
+		}
+		value = strings.Map(func(r rune) rune {
+			switch {
+			case r >= '0' && r <= '9' || r == '.' || r == ',' || r == '-':
+				return r
+			case unicode.IsSpace(r):
+				return ' '
+			}
+			// Drops currency symbols
+			return -1
+		}, value)
+		// Spaces left between digits group thousands, as in 1 299,00
+		value = strings.TrimSpace(value)
+		grouped := strings.Contains(value, " ")
+		value = normalizeSeparators(strings.ReplaceAll(value, " ", ""), grouped)
+	}
+
+	if amount, err := strconv.Atoi(value); err == nil {
+		return amount, nil
+	}
+
+	major, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(major) || math.IsInf(major, 0) {
+		return 0, fmt.Errorf("invalid price amount %s", data)
+	}
+	return int(math.Round(major * math.Pow10(minorDigits(currency)))), nil
+}
+
+// normalizeSeparators rewrites an amount written with decimal and thousands
+// separators in either convention, such as 1,299.00 or 1.299,00, with a
+// single '.' decimal point. When both separators appear, the last one is the
+// decimal point. A single comma followed by one or two digits is a decimal
+// comma, other commas group thousands. A single dot is a decimal point,
+// several group thousands. An amount that had separators, or was grouped
+// by spaces, always keeps a decimal point, so it is read in the major unit.
+func normalizeSeparators(value string, grouped bool) string {
+	lastDot := strings.LastIndex(value, ".")
+	lastComma := strings.LastIndex(value, ",")
+
+	switch {
+	case lastDot < 0 && lastComma < 0:
+		if !grouped {
+			return value
+		}
+	case lastDot >= 0 && lastComma >= 0:
+		if lastComma > lastDot {
+			value = strings.ReplaceAll(value, ".", "")
+			value = strings.Replace(value, ",", ".", 1)
+		} else {
+			value = strings.ReplaceAll(value, ",", "")
+		}
+	case lastComma >= 0:
+		if strings.Count(value, ",") == 1 && len(value)-lastComma-1 <= 2 {
+			value = strings.Replace(value, ",", ".", 1)
+		} else {
+			value = strings.ReplaceAll(value, ",", "")
+		}
+	default:
+		if strings.Count(value, ".") > 1 {
+			value = strings.ReplaceAll(value, ".", "")
+		}
+	}
+
+	if !strings.Contains(value, ".") {
+		value += ".0"
+	}
+	return value
+}
+
+// minorDigits returns the number of minor unit digits of the currency.
+func minorDigits(currency string) int {
+	if format, ok := currencyFormats[strings.ToUpper(currency)]; ok {
+		return format.decimals
+	}
+	return 2
+}
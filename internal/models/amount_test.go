@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDisplayPriceUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want int
+	}{
+		{"minor unit integer", `{"amount":12900,"currency":"USD"}`, 12900},
+		{"major unit float", `{"amount":129.5,"currency":"USD"}`, 12950},
+		{"null", `{"amount":null,"currency":"USD"}`, 0},
+		{"missing", `{"currency":"USD"}`, 0},
+		{"minor unit string", `{"amount":"12900","currency":"USD"}`, 12900},
+		{"decimal point", `{"amount":"$129.00","currency":"USD"}`, 12900},
+		{"thousands comma", `{"amount":"$1,299","currency":"USD"}`, 129900},
+		{"thousands comma and decimal point", `{"amount":"$1,299.00","currency":"USD"}`, 129900},
+		{"several thousands commas", `{"amount":"1,299,000","currency":"USD"}`, 129900000},
+		{"decimal comma", `{"amount":"12,99 €","currency":"EUR"}`, 1299},
+		{"thousands dot and decimal comma", `{"amount":"1.299,00 €","currency":"EUR"}`, 129900},
+		{"several thousands dots", `{"amount":"1.299.000","currency":"EUR"}`, 129900000},
+		{"thousands space", `{"amount":"1 299 €","currency":"EUR"}`, 129900},
+		{"thousands space and decimal comma", `{"amount":"1 299,50 €","currency":"EUR"}`, 129950},
+		{"thousands comma without minor unit", `{"amount":"¥1,299","currency":"JPY"}`, 1299},
+		{"negative", `{"amount":"-$1,299.50","currency":"USD"}`, -129950},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var price DisplayPrice
+			if err := json.Unmarshal([]byte(tt.json), &price); err != nil {
+				t.Fatalf("Unmarshal(%s) failed: %v", tt.json, err)
+			}
+			if price.Amount != tt.want {
+				t.Errorf("Unmarshal(%s) amount = %d, want %d", tt.json, price.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayPriceUnmarshalJSONInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"amount":"TBA","currency":"USD"}`,
+		`{"amount":"1.2.3,4,5","currency":"USD"}`,
+		`{"amount":"1,2,3.4.5","currency":"USD"}`,
+		`{"amount":true,"currency":"USD"}`,
+	} {
+		var price DisplayPrice
+		if err := json.Unmarshal([]byte(data), &price); err == nil {
+			t.Errorf("Unmarshal(%s) = %d, want an error", data, price.Amount)
+		}
+	}
+}
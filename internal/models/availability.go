@@ -0,0 +1,39 @@
+package models
+
+import "strings"
+
+// Availability is the normalized stock status of a product or variant.
+type Availability string
+
+const (
+	AvailabilityUnknown    Availability = ""
+	AvailabilityInStock    Availability = "in_stock"
+	AvailabilityComingSoon Availability = "coming_soon"
+	AvailabilitySoldOut    Availability = "sold_out"
+)
+
+// ParseAvailability normalizes the store's status strings, such as
+// "Available", "COMING_SOON" or "SoldOut". Unrecognized values are unknown.
+func ParseAvailability(status string) Availability {
+	normalized := strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(status))
+	switch normalized {
+	case "available", "instock", "live":
+		return AvailabilityInStock
+	case "comingsoon", "upcoming", "preorder":
+		return AvailabilityComingSoon
+	case "soldout", "outofstock", "unavailable":
+		return AvailabilitySoldOut
+	default:
+		return AvailabilityUnknown
+	}
+}
+
+// Availability returns the variant's stock status.
+func (v Variant) Availability() Availability {
+	return ParseAvailability(v.Status)
+}
+
+// Availability returns the product's overall stock status.
+func (p Product) Availability() Availability {
+	return ParseAvailability(p.Status)
+}
@@ -0,0 +1,32 @@
+package models
+
+// ProductDetail holds what a product's own page lists beyond the category
+// listing. It is only fetched when enrich_alerts is enabled.
+type ProductDetail struct {
+	// Images are the URLs of the product's gallery
+	Images []string `json:"images,omitempty"`
+	Specs  []Spec   `json:"specs,omitempty"`
+	Stock  []Stock  `json:"stock,omitempty"`
+	// CompatibleWith lists the products an accessory works with, when its
+	// page names them
+	CompatibleWith []CompatibleProduct `json:"compatibleWith,omitempty"`
+}
+
+// CompatibleProduct is a product that another one works with. URL is empty
+// when the page only names it.
+type CompatibleProduct struct {
+	Title string `json:"title"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Spec is a single line of a product's specifications.
+type Spec struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Stock is the availability of a single SKU.
+type Stock struct {
+	SKU    string `json:"sku"`
+	Status string `json:"status"`
+}
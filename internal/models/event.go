@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// EventType identifies the kind of change the monitor detected.
+type EventType string
+
+const (
+	EventNew         EventType = "new"
+	EventRemoved     EventType = "removed"
+	EventBackInStock EventType = "back_in_stock"
+	EventPriceChange EventType = "price_change"
+	EventSale        EventType = "sale"
+	EventUpdated     EventType = "updated"
+	EventInStock     EventType = "in_stock"
+	// EventNewSubCategory announces a subcategory that appeared in a
+	// category listing, often ahead of a new product line
+	EventNewSubCategory EventType = "new_subcategory"
+	// EventUpcoming announces a new product listed with a placeholder, such
+	// as a zero price, ahead of its launch
+	EventUpcoming EventType = "upcoming"
+	// EventAvailable follows an upcoming product once its real price appears
+	EventAvailable EventType = "available"
+	// EventSoldOut announces variants of a watched product that sold out
+	EventSoldOut EventType = "sold_out"
+)
+
+type Event struct {
+	Type    EventType
+	Product Product
+	// Category is the store category the product was found in, if known
+	Category string
+	// Variants holds the variants that triggered a back-in-stock, in-stock,
+	// price change or sale event
+	Variants []Variant
+	// OldPrices maps variant IDs to their previous price for price changes
+	OldPrices map[string]int
+	// Previous is the stored record before an update event
+	Previous *Product
+	// SubCategory is the title of the subcategory a new_subcategory event
+	// announces. Only Category and the storefront fields of Product are set
+	// for those events.
+	SubCategory string
+	// DetectedAt is when the monitor noticed the change, which may be well
+	// before the alert is sent when alerts are batched or digested
+	DetectedAt time.Time
+	// Detail holds the product page details of new product events when
+	// enrich_alerts is enabled
+	Detail *ProductDetail
+}
+
+// Title names what the event is about: the product, or the new subcategory
+// with the storefront prefix products get.
+func (e Event) Title() string {
+	if e.Type == EventNewSubCategory {
+		product := e.Product
+		product.Title = e.SubCategory
+		return product.DisplayTitle()
+	}
+	return e.Product.DisplayTitle()
+}
+
+// URL links to the product, or to the category listing a new subcategory
+// appeared in.
+func (e Event) URL() string {
+	if e.Type == EventNewSubCategory {
+		return CategoryURL(e.Product.StorefrontPath, e.Category)
+	}
+	return e.Product.URL()
+}
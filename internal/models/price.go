@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type currencyFormat struct {
+	symbol   string
+	decimals int
+}
+
+// currencyFormats lists the symbol and number of minor unit digits of the
+// currencies the store is likely to quote prices in.
+var currencyFormats = map[string]currencyFormat{
+	"USD": {"$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+	"KRW": {"₩", 0},
+	"CAD": {"CA$", 2},
+	"AUD": {"A$", 2},
+	"CHF": {"CHF ", 2},
+	"CNY": {"CN¥", 2},
+	"INR": {"₹", 2},
+	"SGD": {"S$", 2},
+}
+
+// FormatPrice renders an amount given in the currency's minor unit, such as
+// cents, for display. An empty currency is treated as USD and unknown
+// currencies are shown with their code after the amount.
+func FormatPrice(amount int, currency string) string {
+	code := strings.ToUpper(currency)
+	if code == "" {
+		code = "USD"
+	}
+
+	format, known := currencyFormats[code]
+	if !known {
+		format.decimals = 2
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	value := fmt.Sprint(amount)
+	if format.decimals > 0 {
+		divisor := 1
+		for range format.decimals {
+			divisor *= 10
+		}
+		value = fmt.Sprintf("%d.%0*d", amount/divisor, format.decimals, amount%divisor)
+	}
+
+	if !known {
+		return sign + value + " " + code
+	}
+	return sign + format.symbol + value
+}
+
+// PriceTBA is shown instead of the zero price the store lists products with
+// before their launch.
+const PriceTBA = "TBA"
+
+// Price formats the variant's display price.
+func (v Variant) Price() string {
+	if v.DisplayPrice.Amount == 0 {
+		return PriceTBA
+	}
+	return FormatPrice(v.DisplayPrice.Amount, v.DisplayPrice.Currency)
+}
+
+// PriceRange formats the lowest and highest variant prices as "min – max",
+// or as a single price when every variant costs the same. Variants without a
+// price yet are left out unless none has one. It is empty for a product
+// without variants.
+func (p Product) PriceRange() string {
+	if len(p.Variants) == 0 {
+		return ""
+	}
+
+	priced := slices.DeleteFunc(slices.Clone(p.Variants), func(v Variant) bool {
+		return v.DisplayPrice.Amount == 0
+	})
+	if len(priced) == 0 {
+		return PriceTBA
+	}
+
+	low, high := priced[0], priced[0]
+	for _, variant := range priced[1:] {
+		if variant.DisplayPrice.Amount < low.DisplayPrice.Amount {
+			low = variant
+		}
+		if variant.DisplayPrice.Amount > high.DisplayPrice.Amount {
+			high = variant
+		}
+	}
+
+	if low.DisplayPrice.Amount == high.DisplayPrice.Amount {
+		return low.Price()
+	}
+	return low.Price() + " – " + high.Price()
+}
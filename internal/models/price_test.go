@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestFormatPrice(t *testing.T) {
+	tests := []struct {
+		amount   int
+		currency string
+		want     string
+	}{
+		{12999, "USD", "$129.99"},
+		{5, "USD", "$0.05"},
+		{12900, "", "$129.00"},
+		{-1250, "USD", "-$12.50"},
+		{12999, "EUR", "€129.99"},
+		{12999, "eur", "€129.99"},
+		{4900, "GBP", "£49.00"},
+		{12999, "JPY", "¥12999"},
+		{0, "JPY", "¥0"},
+		{12999, "SEK", "129.99 SEK"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatPrice(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("FormatPrice(%d, %q) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
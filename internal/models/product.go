@@ -1,12 +1,26 @@
 package models
 
+import "time"
+
 type Product struct {
 	ID               string    `json:"id"`
 	Title            string    `json:"title"`
 	ShortDescription string    `json:"shortDescription"`
 	Slug             string    `json:"slug"`
+	Status           string    `json:"status,omitempty"`
 	Thumbnail        Thumbnail `json:"thumbnail"`
 	Variants         []Variant `json:"variants"`
+	// Categories lists the store categories the product was found in,
+	// FirstSeen when the monitor first recorded it and LastSeen when it was
+	// last listed. They are kept by the monitor and not part of the store's
+	// response.
+	Categories []string  `json:"categories,omitempty"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	// Storefront and StorefrontPath identify the storefront the product was
+	// found in. Both are empty for the main store.
+	Storefront     string `json:"storefront,omitempty"`
+	StorefrontPath string `json:"storefrontPath,omitempty"`
 }
 
 type Thumbnail struct {
@@ -14,17 +28,35 @@ type Thumbnail struct {
 }
 
 type Variant struct {
-	ID           string `json:"id"`
-	DisplayPrice struct {
-		Amount   int    `json:"amount"`
-		Currency string `json:"currency"`
-	} `json:"displayPrice"`
+	ID           string       `json:"id"`
+	Status       string       `json:"status,omitempty"`
+	DisplayPrice DisplayPrice `json:"displayPrice"`
+}
+
+// DisplayPrice is a variant's price. Amount is given in the currency's
+// minor unit, such as cents.
+type DisplayPrice struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// SubCategory is a group of products within a category listing.
+type SubCategory struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Products []Product `json:"products"`
+}
+
+// Name identifies the subcategory, preferring its ID.
+func (s SubCategory) Name() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	return s.Title
 }
 
 type PageProps struct {
-	SubCategories []struct {
-		Products []Product `json:"products"`
-	} `json:"subCategories"`
+	SubCategories []SubCategory `json:"subCategories"`
 }
 
 type Response struct {
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// StoreURL is the address of the UniFi store.
+	StoreURL = "https://store.ui.com"
+	// DefaultStorefrontPath is the path of the main storefront.
+	DefaultStorefrontPath = "us/en"
+)
+
+// URL links to the product's page on the storefront it was found in.
+func (p Product) URL() string {
+	path := p.StorefrontPath
+	if path == "" {
+		path = DefaultStorefrontPath
+	}
+	return fmt.Sprintf("%s/%s/products/%s", StoreURL, path, p.Slug)
+}
+
+// CategoryURL links to a category listing on the storefront with the given
+// path, or on the main storefront when the path is empty.
+func CategoryURL(storefrontPath, category string) string {
+	if storefrontPath == "" {
+		storefrontPath = DefaultStorefrontPath
+	}
+	return fmt.Sprintf("%s/%s/category/%s", StoreURL, storefrontPath, category)
+}
+
+// Region is the store region the product was found in, such as us or uk.
+func (p Product) Region() string {
+	path := p.StorefrontPath
+	if path == "" {
+		path = DefaultStorefrontPath
+	}
+	region, _, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	return strings.ToLower(region)
+}
+
+// DisplayTitle is the title shown in alerts. Products from storefronts other
+// than the main one are prefixed with the storefront's name, so a
+// refurbished deal isn't mistaken for a new launch.
+func (p Product) DisplayTitle() string {
+	if p.Storefront == "" {
+		return p.Title
+	}
+	return fmt.Sprintf("[%s] %s", p.Storefront, p.Title)
+}
@@ -0,0 +1,295 @@
+// Package nats publishes detection events to a NATS subject. It speaks the
+// small subset of the NATS client protocol needed to publish, so no client
+// library is required.
+package nats
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+const defaultPort = "4222"
+
+// message is the JSON document published for every event.
+type message struct {
+	Type        models.EventType      `json:"type"`
+	Time        time.Time             `json:"time"`
+	Category    string                `json:"category,omitempty"`
+	URL         string                `json:"url"`
+	Product     models.Product        `json:"product"`
+	Variants    []models.Variant      `json:"variants,omitempty"`
+	OldPrices   map[string]int        `json:"oldPrices,omitempty"`
+	Previous    *models.Product       `json:"previous,omitempty"`
+	SubCategory string                `json:"subCategory,omitempty"`
+	Detail      *models.ProductDetail `json:"detail,omitempty"`
+}
+
+// serverInfo is the part of the server's INFO line the publisher needs.
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// connectOptions is sent with CONNECT. Verbose is off, so the server only
+// answers PING and errors.
+type connectOptions struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+// Publisher publishes events to a NATS subject. The connection is opened on
+// the first event and reopened after it breaks, so an unreachable server
+// only fails the events sent while it is down.
+type Publisher struct {
+	server  *url.URL
+	subject string
+	timeout time.Duration
+	dryRun  bool
+	retry   customhttp.RetryPolicy
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	info   serverInfo
+}
+
+// New returns a publisher for the server, given as nats://host:port or
+// tls://host:port with optional user:pass@ or token@ credentials.
+func New(serverURL, subject string) (*Publisher, error) {
+	server, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats_url: %w", err)
+	}
+	switch server.Scheme {
+	case "nats", "tls":
+	default:
+		return nil, fmt.Errorf("invalid nats_url: unsupported scheme %q", server.Scheme)
+	}
+	if server.Hostname() == "" {
+		return nil, fmt.Errorf("invalid nats_url: missing host")
+	}
+
+	return &Publisher{
+		server:  server,
+		subject: subject,
+		timeout: 10 * time.Second,
+	}, nil
+}
+
+func (p *Publisher) Name() string {
+	return "nats"
+}
+
+// SetDryRun makes the publisher log messages instead of publishing them.
+func (p *Publisher) SetDryRun(enabled bool) {
+	p.dryRun = enabled
+}
+
+// SetTimeout changes how long connecting and publishing may take.
+func (p *Publisher) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// SetRetryPolicy retries messages that couldn't be published because the
+// server was unreachable or dropped the connection.
+func (p *Publisher) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	p.retry = policy
+}
+
+func (p *Publisher) SendProduct(product models.Product) error {
+	return p.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (p *Publisher) SendEvent(event models.Event) error {
+	payload, err := json.Marshal(message{
+		Type:        event.Type,
+		Time:        time.Now().UTC(),
+		Category:    event.Category,
+		URL:         event.URL(),
+		Product:     event.Product,
+		Variants:    event.Variants,
+		OldPrices:   event.OldPrices,
+		Previous:    event.Previous,
+		SubCategory: event.SubCategory,
+		Detail:      event.Detail,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal nats message: %w", err)
+	}
+
+	if p.dryRun {
+		logger.Info().
+			Str("subject", p.subject).
+			RawJSON("payload", payload).
+			Msg("Dry run, skipping NATS message")
+		return nil
+	}
+
+	return p.retry.Do(p.Name(), func() error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		// A connection the server dropped while idle only shows up as
+		// broken when it's used, so retry once on a fresh connection
+		reused := p.conn != nil
+		err := p.publish(payload)
+		if err != nil && reused {
+			p.close()
+			err = p.publish(payload)
+		}
+		if err != nil {
+			p.close()
+			return err
+		}
+		return nil
+	})
+}
+
+// publish sends the payload and waits for the server to acknowledge it with
+// PONG, so an error or a dropped connection is reported to the caller.
+// Must be called with the mutex held.
+func (p *Publisher) publish(payload []byte) error {
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	if p.info.MaxPayload > 0 && len(payload) > p.info.MaxPayload {
+		return fmt.Errorf("nats message of %d bytes exceeds the server limit of %d", len(payload), p.info.MaxPayload)
+	}
+
+	if err := p.conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return fmt.Errorf("failed to set nats deadline: %w", err)
+	}
+
+	command := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", p.subject, len(payload), payload)
+	if _, err := p.conn.Write([]byte(command)); err != nil {
+		return fmt.Errorf("failed to publish nats message: %w", err)
+	}
+
+	return p.awaitPong()
+}
+
+// connect dials the server and completes the INFO/CONNECT handshake. Must
+// be called with the mutex held.
+func (p *Publisher) connect() error {
+	address := net.JoinHostPort(p.server.Hostname(), valueOr(p.server.Port(), defaultPort))
+
+	conn, err := net.DialTimeout("tcp", address, p.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set nats deadline: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to read nats server info: %w", err)
+	}
+	info, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("unexpected nats greeting: %q", line)
+	}
+	if err := json.Unmarshal([]byte(info), &p.info); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to decode nats server info: %w", err)
+	}
+
+	if p.server.Scheme == "tls" || p.info.TLSRequired {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: p.server.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to start nats TLS: %w", err)
+		}
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options := connectOptions{Name: "unifi-monitor", Lang: "go", Version: "1.0.0"}
+	if user := p.server.User; user != nil {
+		if pass, ok := user.Password(); ok {
+			options.User, options.Pass = user.Username(), pass
+		} else {
+			options.AuthToken = user.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to marshal nats connect options: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send nats connect: %w", err)
+	}
+
+	p.conn, p.reader = conn, reader
+	if err := p.awaitPong(); err != nil {
+		p.close()
+		return err
+	}
+
+	logger.Info().Str("server", p.server.Host).Msg("Connected to NATS")
+	return nil
+}
+
+// awaitPong reads until the server answers our PING, replying to its own
+// PINGs on the way. Must be called with the mutex held.
+func (p *Publisher) awaitPong() error {
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read nats response: %w", err)
+		}
+
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("failed to answer nats ping: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats returned an error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// close drops the connection so the next event reconnects. Must be called
+// with the mutex held.
+func (p *Publisher) close() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.reader = nil, nil
+	}
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,51 @@
+package notifier
+
+import (
+	"math/rand/v2"
+
+	"all-unifi-monitor/internal/models"
+)
+
+const canaryTag = "[CANARY] "
+
+// Canary mirrors a random sample of events to a test notifier, tagging each
+// one so it can't be mistaken for a production alert. It is meant for
+// trying out filter changes against live traffic.
+type Canary struct {
+	notifier EventNotifier
+	rate     float64
+}
+
+// NewCanary returns a notifier that forwards each event to n with
+// probability rate, between 0 and 1.
+func NewCanary(n EventNotifier, rate float64) *Canary {
+	return &Canary{notifier: n, rate: rate}
+}
+
+func (c *Canary) Name() string {
+	return "canary"
+}
+
+func (c *Canary) SendProduct(product models.Product) error {
+	return c.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (c *Canary) SendEvent(event models.Event) error {
+	if rand.Float64() >= c.rate {
+		return nil
+	}
+
+	event.Product.Title = canaryTag + event.Product.Title
+	return c.notifier.SendEvent(event)
+}
+
+// SendEvents samples each event on its own.
+func (c *Canary) SendEvents(events []models.Event) error {
+	var lastErr error
+	for _, event := range events {
+		if err := c.SendEvent(event); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,118 @@
+package notifier
+
+import (
+	"sync"
+	"time"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// DigestNotifier is implemented by notifiers that can summarize many events
+// in a single digest message.
+type DigestNotifier interface {
+	Notifier
+	SendDigest([]models.Event) error
+}
+
+// Digest collects events instead of announcing them and sends everything
+// collected as one summary per interval.
+type Digest struct {
+	notifiers []Notifier
+	mutex     sync.Mutex
+	events    []models.Event
+}
+
+// NewDigest returns a notifier that forwards a digest of all events to
+// notifiers every interval.
+func NewDigest(notifiers []Notifier, interval time.Duration) *Digest {
+	d := &Digest{notifiers: notifiers}
+	go d.run(interval)
+	return d
+}
+
+// SetNotifiers replaces the notifiers the next digests are sent to. Events
+// collected so far are kept.
+func (d *Digest) SetNotifiers(notifiers []Notifier) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.notifiers = notifiers
+}
+
+func (d *Digest) Name() string {
+	return "digest"
+}
+
+func (d *Digest) SendProduct(product models.Product) error {
+	return d.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (d *Digest) SendEvent(event models.Event) error {
+	return d.SendEvents([]models.Event{event})
+}
+
+func (d *Digest) SendEvents(events []models.Event) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.events = append(d.events, events...)
+	return nil
+}
+
+func (d *Digest) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.Flush()
+	}
+}
+
+// SendAlert forwards the alert right away instead of holding it for the
+// next digest.
+func (d *Digest) SendAlert(title, message string) error {
+	d.mutex.Lock()
+	notifiers := d.notifiers
+	d.mutex.Unlock()
+
+	Alert(logger.Logger{}, notifiers, title, message)
+	return nil
+}
+
+// Flush sends the events collected so far. Notifiers without digest support
+// receive them as a batch.
+func (d *Digest) Flush() {
+	d.mutex.Lock()
+	events, notifiers := d.events, d.notifiers
+	d.events = nil
+	d.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	log := logger.With("digest", logger.NewID())
+	log.Info().Int("events", len(events)).Msg("Sending digest")
+	sendDigest(log, notifiers, events)
+}
+
+// sendDigest sends events as a digest to the notifiers that support them and
+// as a batch to the others.
+func sendDigest(log logger.Logger, notifiers []Notifier, events []models.Event) {
+	var digests, batched []Notifier
+	for _, n := range notifiers {
+		if _, ok := n.(DigestNotifier); ok {
+			digests = append(digests, n)
+		} else {
+			batched = append(batched, n)
+		}
+	}
+
+	fanOut(log, digests, func(n Notifier) error {
+		return n.(DigestNotifier).SendDigest(events)
+	})
+	if len(batched) > 0 {
+		DispatchBatch(log, batched, events)
+	}
+}
@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// recorder records the events sent to it, one by one or as digests.
+type recorder struct {
+	name    string
+	mutex   sync.Mutex
+	events  []models.Event
+	digests [][]models.Event
+}
+
+func (r *recorder) Name() string {
+	return valueOr(r.name, "recorder")
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func (r *recorder) SendProduct(product models.Product) error {
+	return r.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (r *recorder) SendEvent(event models.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recorder) SendDigest(events []models.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.digests = append(r.digests, events)
+	return nil
+}
+
+func newEvent(id string) models.Event {
+	return models.Event{Type: models.EventNew, Product: models.Product{ID: id, Title: "Product " + id}}
+}
+
+func TestDrainSendsDigest(t *testing.T) {
+	r := &recorder{}
+	digest := NewDigest([]Notifier{r}, time.Hour)
+
+	if err := digest.SendEvents([]models.Event{newEvent("A"), newEvent("B")}); err != nil {
+		t.Fatalf("SendEvents() failed: %v", err)
+	}
+	if len(r.digests) != 0 {
+		t.Fatalf("sent digests %v before the interval", r.digests)
+	}
+
+	Drain([]Notifier{digest})
+	if len(r.digests) != 1 || len(r.digests[0]) != 2 {
+		t.Fatalf("sent digests %v on drain, want one of 2 events", r.digests)
+	}
+
+	// Nothing is sent twice
+	Drain([]Notifier{digest})
+	if len(r.digests) != 1 {
+		t.Errorf("sent %d digests after draining twice, want 1", len(r.digests))
+	}
+}
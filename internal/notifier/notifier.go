@@ -0,0 +1,319 @@
+package notifier
+
+import (
+	"slices"
+	"sync"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/discord"
+	"all-unifi-monitor/internal/email"
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/matrix"
+	"all-unifi-monitor/internal/message"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/nats"
+	"all-unifi-monitor/internal/ntfy"
+	"all-unifi-monitor/internal/pushover"
+	"all-unifi-monitor/internal/teams"
+	"all-unifi-monitor/internal/telegram"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// Notifier is a backend that new products are announced through.
+type Notifier interface {
+	SendProduct(models.Product) error
+	Name() string
+}
+
+// EventNotifier is implemented by notifiers that can also announce removals,
+// price changes and other non-new-product events.
+type EventNotifier interface {
+	Notifier
+	SendEvent(models.Event) error
+}
+
+// BatchNotifier is implemented by notifiers that can announce several events
+// in a single message.
+type BatchNotifier interface {
+	Notifier
+	SendEvents([]models.Event) error
+}
+
+// knownNotifiers are the names notifier_events accepts.
+var knownNotifiers = []string{"discord", "canary", "telegram", "ntfy", "pushover", "teams", "email", "matrix", "nats"}
+
+// AlertNotifier is implemented by notifiers that can deliver operational
+// alerts, such as a warning that the monitor may be broken.
+type AlertNotifier interface {
+	Notifier
+	SendAlert(title, message string) error
+}
+
+// FromConfig returns the notifiers to announce events through. In digest
+// mode that is a single Digest wrapping every enabled backend. With quiet
+// hours the backends are wrapped in a Quiet first.
+func FromConfig(cfg *config.Config) ([]Notifier, error) {
+	notifiers, err := Backends(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	quietHours, err := cfg.QuietHours()
+	if err != nil {
+		return nil, err
+	}
+	if quietHours != nil && len(notifiers) > 0 {
+		notifiers = []Notifier{NewQuiet(notifiers, quietHours)}
+	}
+
+	if cfg.NotifyMode == config.NotifyModeDigest && len(notifiers) > 0 {
+		return []Notifier{NewDigest(notifiers, cfg.DigestInterval)}, nil
+	}
+
+	return notifiers, nil
+}
+
+// Rewire points the Digest or Quiet wrapping the current notifiers at the
+// reloaded backends, so the events they hold aren't lost, and returns the
+// notifiers to use from now on.
+func Rewire(current, backends []Notifier) []Notifier {
+	if len(current) != 1 {
+		return backends
+	}
+
+	switch n := current[0].(type) {
+	case *Digest:
+		n.mutex.Lock()
+		inner := n.notifiers
+		n.mutex.Unlock()
+		n.SetNotifiers(Rewire(inner, backends))
+	case *Quiet:
+		n.SetNotifiers(backends)
+	default:
+		return backends
+	}
+	return current
+}
+
+// Drain sends the events held back by the Digest and Quiet wrapping
+// notifiers right away. They only live in memory, so it must be called
+// before the monitor exits.
+func Drain(notifiers []Notifier) {
+	for _, n := range notifiers {
+		switch n := n.(type) {
+		case *Digest:
+			n.Flush()
+			// A digest due during quiet hours is held by the Quiet inside
+			n.mutex.Lock()
+			inner := n.notifiers
+			n.mutex.Unlock()
+			Drain(inner)
+		case *Quiet:
+			n.Flush()
+		}
+	}
+}
+
+// Backends returns every notifier enabled in the config. Notifier settings
+// that can't work, such as a malformed webhook URL, are reported as errors.
+func Backends(cfg *config.Config) ([]Notifier, error) {
+	var notifiers []Notifier
+	retry := customhttp.RetryPolicy{MaxAttempts: cfg.NotifyMaxAttempts, Backoff: cfg.NotifyRetryBackoff}
+
+	template, err := message.Parse(cfg.MessageTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback Notifier
+	if cfg.FallbackWebhookURL != "" {
+		webhook := discord.New(cfg.FallbackWebhookURL, cfg.Discord)
+		webhook.SetDryRun(cfg.DryRun)
+		webhook.SetTimeout(cfg.HTTPTimeout)
+		webhook.SetRetryPolicy(retry)
+		webhook.SetMessageTemplate(template)
+		if err := webhook.ValidateAs("fallback_webhook_url", cfg.VerifyWebhooks); err != nil {
+			return nil, err
+		}
+		fallback = webhook
+	}
+	// guard disables webhooks that were deleted or revoked
+	guard := func(n Notifier) Notifier {
+		if cfg.WebhookRevokedAfter == 0 {
+			return n
+		}
+		return NewRevocable(n, cfg.WebhookRevokedAfter, fallback)
+	}
+
+	if cfg.DiscordWebhookURL != "" || len(cfg.CategoryWebhooks) > 0 {
+		webhook := discord.New(cfg.DiscordWebhookURL, cfg.Discord)
+		webhook.SetCategoryWebhooks(cfg.CategoryWebhooks)
+		webhook.SetMessageTemplate(template)
+		webhook.SetDryRun(cfg.DryRun)
+		webhook.SetTimeout(cfg.HTTPTimeout)
+		webhook.SetRetryPolicy(retry)
+		if err := webhook.Validate(cfg.VerifyWebhooks); err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, guard(webhook))
+	}
+
+	if cfg.CanaryWebhookURL != "" && cfg.CanarySampleRate > 0 {
+		webhook := discord.New(cfg.CanaryWebhookURL, cfg.Discord)
+		webhook.SetDryRun(cfg.DryRun)
+		webhook.SetTimeout(cfg.HTTPTimeout)
+		webhook.SetRetryPolicy(retry)
+		webhook.SetMessageTemplate(template)
+		if err := webhook.ValidateAs("canary_webhook_url", cfg.VerifyWebhooks); err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, NewCanary(webhook, cfg.CanarySampleRate))
+	}
+
+	if cfg.TelegramBotToken != "" && cfg.TelegramChatID != "" {
+		bot := telegram.New(cfg.TelegramBotToken, cfg.TelegramChatID)
+		bot.SetDryRun(cfg.DryRun)
+		bot.SetTimeout(cfg.HTTPTimeout)
+		bot.SetRetryPolicy(retry)
+		bot.SetMessageTemplate(template)
+		notifiers = append(notifiers, bot)
+	}
+
+	if cfg.NtfyTopic != "" {
+		topic := ntfy.New(cfg.NtfyServer, cfg.NtfyTopic)
+		topic.SetDryRun(cfg.DryRun)
+		topic.SetTimeout(cfg.HTTPTimeout)
+		topic.SetRetryPolicy(retry)
+		topic.SetMessageTemplate(template)
+		notifiers = append(notifiers, topic)
+	}
+
+	if cfg.PushoverAppToken != "" && cfg.PushoverUserKey != "" {
+		client := pushover.New(cfg.PushoverAppToken, cfg.PushoverUserKey)
+		client.SetDryRun(cfg.DryRun)
+		client.SetTimeout(cfg.HTTPTimeout)
+		client.SetRetryPolicy(retry)
+		notifiers = append(notifiers, client)
+	}
+
+	if cfg.TeamsWebhookURL != "" {
+		webhook := teams.New(cfg.TeamsWebhookURL)
+		webhook.SetDryRun(cfg.DryRun)
+		webhook.SetTimeout(cfg.HTTPTimeout)
+		webhook.SetRetryPolicy(retry)
+		notifiers = append(notifiers, guard(webhook))
+	}
+
+	if cfg.SMTPHost != "" {
+		mailer := email.New(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.EmailFrom, cfg.EmailTo)
+		mailer.SetDryRun(cfg.DryRun)
+		mailer.SetTimeout(cfg.HTTPTimeout)
+		mailer.SetRetryPolicy(retry)
+		notifiers = append(notifiers, mailer)
+	}
+
+	if cfg.MatrixHomeserver != "" && cfg.MatrixAccessToken != "" && cfg.MatrixRoomID != "" {
+		room := matrix.New(cfg.MatrixHomeserver, cfg.MatrixAccessToken, cfg.MatrixRoomID)
+		room.SetDryRun(cfg.DryRun)
+		room.SetTimeout(cfg.HTTPTimeout)
+		room.SetRetryPolicy(retry)
+		notifiers = append(notifiers, room)
+	}
+
+	if cfg.NATSURL != "" {
+		publisher, err := nats.New(cfg.NATSURL, cfg.NATSSubject)
+		if err != nil {
+			return nil, err
+		}
+		publisher.SetDryRun(cfg.DryRun)
+		publisher.SetTimeout(cfg.HTTPTimeout)
+		publisher.SetRetryPolicy(retry)
+		notifiers = append(notifiers, publisher)
+	}
+
+	// A disabled webhook is reported through every other notifier
+	for _, n := range notifiers {
+		if r, ok := n.(*Revocable); ok {
+			r.escalate = slices.DeleteFunc(slices.Clone(notifiers), func(other Notifier) bool { return other == n })
+		}
+	}
+
+	return subscribe(notifiers, cfg.NotifierEvents)
+}
+
+// Dispatch sends the event to every notifier concurrently. Failures are
+// logged per notifier through log so one broken backend doesn't hold up the
+// others. Notifiers that only support new products skip other event types.
+func Dispatch(log logger.Logger, notifiers []Notifier, event models.Event) {
+	fanOut(log, notifiers, func(n Notifier) error {
+		return send(n, event)
+	})
+}
+
+// DispatchBatch announces several events, using a single message for
+// notifiers that support batching and one message per event otherwise.
+func DispatchBatch(log logger.Logger, notifiers []Notifier, events []models.Event) {
+	fanOut(log, notifiers, func(n Notifier) error {
+		return sendBatch(n, events)
+	})
+}
+
+// Alert sends an operational alert to every notifier that supports them.
+func Alert(log logger.Logger, notifiers []Notifier, title, message string) {
+	fanOut(log, notifiers, func(n Notifier) error {
+		if an, ok := n.(AlertNotifier); ok {
+			return an.SendAlert(title, message)
+		}
+		return nil
+	})
+}
+
+func send(n Notifier, event models.Event) error {
+	if en, ok := n.(EventNotifier); ok {
+		return en.SendEvent(event)
+	}
+	if isProductEvent(event.Type) {
+		return n.SendProduct(event.Product)
+	}
+	return nil
+}
+
+// isProductEvent reports whether notifiers that only support new products
+// can announce events of the type.
+func isProductEvent(eventType models.EventType) bool {
+	switch eventType {
+	case models.EventNew, models.EventUpcoming, models.EventAvailable:
+		return true
+	}
+	return false
+}
+
+// sendBatch sends events in a single message when n supports batching and
+// one message per event otherwise.
+func sendBatch(n Notifier, events []models.Event) error {
+	if bn, ok := n.(BatchNotifier); ok {
+		return bn.SendEvents(events)
+	}
+
+	var lastErr error
+	for _, event := range events {
+		if err := send(n, event); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func fanOut(log logger.Logger, notifiers []Notifier, send func(Notifier) error) {
+	var wg sync.WaitGroup
+	for _, n := range notifiers {
+		wg.Add(1)
+		go func(n Notifier) {
+			defer wg.Done()
+			if err := send(n); err != nil {
+				log.Error().Err(err).Str("notifier", n.Name()).Msg("Failed to send notification")
+			}
+		}(n)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,125 @@
+package notifier
+
+import (
+	"sync"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// Quiet holds events back during quiet hours and sends everything held as a
+// single digest when the window ends. Outside quiet hours events are sent
+// right away. Operational alerts are never held.
+type Quiet struct {
+	hours     *config.QuietHours
+	mutex     sync.Mutex
+	notifiers []Notifier
+	held      []models.Event
+	flushing  bool
+}
+
+// NewQuiet returns a notifier that forwards events to notifiers outside of
+// the quiet hours window.
+func NewQuiet(notifiers []Notifier, hours *config.QuietHours) *Quiet {
+	return &Quiet{notifiers: notifiers, hours: hours}
+}
+
+// SetNotifiers replaces the notifiers events are sent to. Events held so far
+// are kept.
+func (q *Quiet) SetNotifiers(notifiers []Notifier) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.notifiers = notifiers
+}
+
+func (q *Quiet) Name() string {
+	return "quiet_hours"
+}
+
+func (q *Quiet) SendProduct(product models.Product) error {
+	return q.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (q *Quiet) SendEvent(event models.Event) error {
+	if notifiers, ok := q.pass([]models.Event{event}); ok {
+		Dispatch(logger.Logger{}, notifiers, event)
+	}
+	return nil
+}
+
+func (q *Quiet) SendEvents(events []models.Event) error {
+	if notifiers, ok := q.pass(events); ok {
+		DispatchBatch(logger.Logger{}, notifiers, events)
+	}
+	return nil
+}
+
+// SendDigest sends a digest collected in digest mode, unless it is due
+// during quiet hours. Then its events are held for the digest sent when the
+// window ends.
+func (q *Quiet) SendDigest(events []models.Event) error {
+	if notifiers, ok := q.pass(events); ok {
+		sendDigest(logger.Logger{}, notifiers, events)
+	}
+	return nil
+}
+
+func (q *Quiet) SendAlert(title, message string) error {
+	q.mutex.Lock()
+	notifiers := q.notifiers
+	q.mutex.Unlock()
+
+	Alert(logger.Logger{}, notifiers, title, message)
+	return nil
+}
+
+// pass returns the notifiers to send events to right away, or holds the
+// events and reports false during quiet hours.
+func (q *Quiet) pass(events []models.Event) ([]Notifier, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	if !q.hours.Active(now) {
+		return q.notifiers, true
+	}
+
+	q.held = append(q.held, events...)
+	if !q.flushing {
+		q.flushing = true
+		end := q.hours.NextEnd(now)
+		logger.Info().Time("until", end).Msg("Quiet hours, holding alerts")
+		time.AfterFunc(time.Until(end), q.flush)
+	}
+	return nil, false
+}
+
+// flush sends the events held during quiet hours as a digest.
+func (q *Quiet) flush() {
+	q.send("Quiet hours ended, sending held alerts")
+}
+
+// Flush sends the events held so far as a digest right away, even during
+// quiet hours.
+func (q *Quiet) Flush() {
+	q.send("Sending alerts held for quiet hours")
+}
+
+func (q *Quiet) send(message string) {
+	q.mutex.Lock()
+	events, notifiers := q.held, q.notifiers
+	q.held = nil
+	q.flushing = false
+	q.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	log := logger.With("digest", logger.NewID())
+	log.Info().Int("events", len(events)).Msg(message)
+	sendDigest(log, notifiers, events)
+}
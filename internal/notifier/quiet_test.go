@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+)
+
+// activeQuietHours returns a window from an hour ago to an hour from now.
+func activeQuietHours() *config.QuietHours {
+	now := time.Now().UTC()
+	offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	day := 24 * time.Hour
+	return &config.QuietHours{
+		Start:    (offset - time.Hour + day) % day,
+		End:      (offset + time.Hour) % day,
+		Location: time.UTC,
+	}
+}
+
+func TestDrainSendsHeldAlerts(t *testing.T) {
+	r := &recorder{}
+	quiet := NewQuiet([]Notifier{r}, activeQuietHours())
+
+	if err := quiet.SendEvent(newEvent("A")); err != nil {
+		t.Fatalf("SendEvent() failed: %v", err)
+	}
+	if len(r.events) != 0 || len(r.digests) != 0 {
+		t.Fatalf("sent %v during quiet hours", r.events)
+	}
+
+	Drain([]Notifier{quiet})
+	if len(r.digests) != 1 || len(r.digests[0]) != 1 || r.digests[0][0].Product.ID != "A" {
+		t.Errorf("sent digests %v on drain, want the held event", r.digests)
+	}
+}
+
+func TestDrainSendsDigestHeldForQuietHours(t *testing.T) {
+	r := &recorder{}
+	digest := NewDigest([]Notifier{NewQuiet([]Notifier{r}, activeQuietHours())}, time.Hour)
+
+	if err := digest.SendEvents([]models.Event{newEvent("A"), newEvent("B")}); err != nil {
+		t.Fatalf("SendEvents() failed: %v", err)
+	}
+
+	Drain([]Notifier{digest})
+	if len(r.digests) != 1 || len(r.digests[0]) != 2 {
+		t.Errorf("sent digests %v on drain, want one of 2 events", r.digests)
+	}
+}
@@ -0,0 +1,157 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// Revocable disables a webhook notifier once it has been rejected as
+// unauthorized or not found several times in a row, which means the webhook
+// was deleted or revoked. From then on its events go to the fallback, if one
+// is configured, and the other notifiers are alerted so the monitor doesn't
+// go dark unnoticed. A config reload enables it again.
+type Revocable struct {
+	notifier Notifier
+	limit    int
+	fallback Notifier
+	// escalate receives the alert sent when the notifier is disabled
+	escalate []Notifier
+
+	mutex    sync.Mutex
+	failures int
+	disabled bool
+}
+
+// NewRevocable returns a notifier that disables n after limit consecutive
+// revoked responses and sends its events to fallback instead. fallback may
+// be nil.
+func NewRevocable(n Notifier, limit int, fallback Notifier) *Revocable {
+	return &Revocable{notifier: n, limit: limit, fallback: fallback}
+}
+
+// Unwrap returns the guarded notifier.
+func (r *Revocable) Unwrap() Notifier {
+	return r.notifier
+}
+
+func (r *Revocable) Name() string {
+	return r.notifier.Name()
+}
+
+func (r *Revocable) SendProduct(product models.Product) error {
+	return r.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (r *Revocable) SendEvent(event models.Event) error {
+	return r.guard(func(n Notifier) error {
+		return send(n, event)
+	})
+}
+
+func (r *Revocable) SendEvents(events []models.Event) error {
+	return r.guard(func(n Notifier) error {
+		return sendBatch(n, events)
+	})
+}
+
+func (r *Revocable) SendDigest(events []models.Event) error {
+	return r.guard(func(n Notifier) error {
+		if dn, ok := n.(DigestNotifier); ok {
+			return dn.SendDigest(events)
+		}
+		return sendBatch(n, events)
+	})
+}
+
+func (r *Revocable) SendAlert(title, message string) error {
+	return r.guard(func(n Notifier) error {
+		if an, ok := n.(AlertNotifier); ok {
+			return an.SendAlert(title, message)
+		}
+		return nil
+	})
+}
+
+// guard calls send with the notifier, or with the fallback once the notifier
+// has been disabled.
+func (r *Revocable) guard(send func(Notifier) error) error {
+	r.mutex.Lock()
+	disabled := r.disabled
+	r.mutex.Unlock()
+
+	if disabled {
+		if r.fallback == nil {
+			return nil
+		}
+		return send(r.fallback)
+	}
+
+	err := send(r.notifier)
+	if r.record(err) {
+		r.escalateRevoked(err)
+		if r.fallback != nil {
+			return send(r.fallback)
+		}
+	}
+	return err
+}
+
+// record counts consecutive revoked responses and reports whether err
+// disabled the notifier.
+func (r *Revocable) record(err error) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err == nil {
+		r.failures = 0
+		return false
+	}
+	if r.disabled || !isRevoked(err) {
+		return false
+	}
+
+	r.failures++
+	if r.failures < r.limit {
+		return false
+	}
+	r.disabled = true
+	return true
+}
+
+func (r *Revocable) escalateRevoked(err error) {
+	logger.Error().
+		Err(err).
+		Str("notifier", r.Name()).
+		Int("failures", r.limit).
+		Bool("fallback", r.fallback != nil).
+		Msg("Notifier disabled, its webhook was deleted or revoked")
+
+	message := fmt.Sprintf("The %s notifier was rejected %d times in a row (%v) and has been disabled. "+
+		"Its webhook was probably deleted or revoked. Fix it and reload the config to enable it again.", r.Name(), r.limit, err)
+	notifiers := r.escalate
+	if r.fallback != nil {
+		notifiers = append(notifiers[:len(notifiers):len(notifiers)], r.fallback)
+	}
+	Alert(logger.Logger{}, notifiers, "Notifier disabled", message)
+}
+
+// isRevoked reports whether err means the webhook no longer exists or no
+// longer accepts our credentials.
+func isRevoked(err error) bool {
+	var statusErr *customhttp.StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
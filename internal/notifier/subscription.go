@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"fmt"
+	"slices"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// eventTypes lists the event types notifiers can subscribe to.
+var eventTypes = []models.EventType{
+	models.EventNew,
+	models.EventUpcoming,
+	models.EventAvailable,
+	models.EventRemoved,
+	models.EventBackInStock,
+	models.EventPriceChange,
+	models.EventSale,
+	models.EventUpdated,
+	models.EventInStock,
+	models.EventSoldOut,
+	models.EventNewSubCategory,
+}
+
+// Subscribed forwards only the event types a notifier subscribed to through
+// notifier_events. Operational alerts are always forwarded.
+type Subscribed struct {
+	notifier Notifier
+	events   map[models.EventType]bool
+}
+
+// subscribe wraps the notifiers listed in subscriptions, keyed by notifier
+// name, so they only receive the given event types.
+func subscribe(notifiers []Notifier, subscriptions map[string][]string) ([]Notifier, error) {
+	names := make([]string, 0, len(notifiers))
+	for _, n := range notifiers {
+		names = append(names, n.Name())
+	}
+
+	events := make(map[string]map[models.EventType]bool, len(subscriptions))
+	for name, types := range subscriptions {
+		if !slices.Contains(knownNotifiers, name) {
+			return nil, fmt.Errorf("notifier_events: unknown notifier %q", name)
+		}
+		if !slices.Contains(names, name) {
+			logger.Warning().Str("notifier", name).Msg("notifier_events lists a notifier that isn't configured")
+		}
+
+		events[name] = make(map[models.EventType]bool, len(types))
+		for _, value := range types {
+			eventType := models.EventType(value)
+			if !slices.Contains(eventTypes, eventType) {
+				return nil, fmt.Errorf("notifier_events.%s: unknown event type %q", name, value)
+			}
+			events[name][eventType] = true
+		}
+	}
+
+	for i, n := range notifiers {
+		types, ok := events[n.Name()]
+		if !ok {
+			continue
+		}
+		if !sendsEvents(n) {
+			for eventType := range types {
+				if !isProductEvent(eventType) {
+					return nil, fmt.Errorf("notifier_events.%s: %s only announces new, upcoming and available products, not %q", n.Name(), n.Name(), eventType)
+				}
+			}
+		}
+		notifiers[i] = &Subscribed{notifier: n, events: types}
+	}
+	return notifiers, nil
+}
+
+// sendsEvents reports whether n can announce every event type, rather than
+// only new products.
+func sendsEvents(n Notifier) bool {
+	if r, ok := n.(*Revocable); ok {
+		n = r.Unwrap()
+	}
+	_, ok := n.(EventNotifier)
+	return ok
+}
+
+// Unwrap returns the notifier receiving the subscribed events.
+func (s *Subscribed) Unwrap() Notifier {
+	return s.notifier
+}
+
+func (s *Subscribed) Name() string {
+	return s.notifier.Name()
+}
+
+func (s *Subscribed) SendProduct(product models.Product) error {
+	if !s.events[models.EventNew] {
+		return nil
+	}
+	return s.notifier.SendProduct(product)
+}
+
+func (s *Subscribed) SendEvent(event models.Event) error {
+	if !s.events[event.Type] {
+		return nil
+	}
+	return send(s.notifier, event)
+}
+
+func (s *Subscribed) SendEvents(events []models.Event) error {
+	events = s.filter(events)
+	if len(events) == 0 {
+		return nil
+	}
+
+	if bn, ok := s.notifier.(BatchNotifier); ok {
+		return bn.SendEvents(events)
+	}
+
+	var lastErr error
+	for _, event := range events {
+		if err := send(s.notifier, event); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (s *Subscribed) SendDigest(events []models.Event) error {
+	if dn, ok := s.notifier.(DigestNotifier); ok {
+		events = s.filter(events)
+		if len(events) == 0 {
+			return nil
+		}
+		return dn.SendDigest(events)
+	}
+	return s.SendEvents(events)
+}
+
+func (s *Subscribed) SendAlert(title, message string) error {
+	if an, ok := s.notifier.(AlertNotifier); ok {
+		return an.SendAlert(title, message)
+	}
+	return nil
+}
+
+func (s *Subscribed) filter(events []models.Event) []models.Event {
+	var filtered []models.Event
+	for _, event := range events {
+		if s.events[event.Type] {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,91 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+
+	"all-unifi-monitor/internal/discord"
+	"all-unifi-monitor/internal/email"
+	"all-unifi-monitor/internal/matrix"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/nats"
+	"all-unifi-monitor/internal/ntfy"
+	"all-unifi-monitor/internal/pushover"
+	"all-unifi-monitor/internal/teams"
+	"all-unifi-monitor/internal/telegram"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// Every backend that notifier_events accepts can announce any event type
+var (
+	_ EventNotifier = (*discord.Webhook)(nil)
+	_ EventNotifier = (*telegram.Bot)(nil)
+	_ EventNotifier = (*ntfy.Topic)(nil)
+	_ EventNotifier = (*pushover.Client)(nil)
+	_ EventNotifier = (*teams.Webhook)(nil)
+	_ EventNotifier = (*matrix.Room)(nil)
+	_ EventNotifier = (*nats.Publisher)(nil)
+	_ EventNotifier = (*email.Mailer)(nil)
+)
+
+// productNotifier only announces new products, like a backend without
+// SendEvent.
+type productNotifier struct {
+	products []models.Product
+}
+
+func (p *productNotifier) Name() string {
+	return "telegram"
+}
+
+func (p *productNotifier) SendProduct(product models.Product) error {
+	p.products = append(p.products, product)
+	return nil
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name          string
+		notifier      Notifier
+		subscriptions map[string][]string
+		wantErr       string
+	}{
+		{"event notifier", &recorder{name: "telegram"}, map[string][]string{"telegram": {"price_change", "removed"}}, ""},
+		{"product notifier with product events", &productNotifier{}, map[string][]string{"telegram": {"new", "available"}}, ""},
+		{"product notifier with price changes", &productNotifier{}, map[string][]string{"telegram": {"new", "price_change"}}, `not "price_change"`},
+		{"unknown notifier", &recorder{name: "telegram"}, map[string][]string{"pager": {"new"}}, `unknown notifier "pager"`},
+		{"unknown event type", &recorder{name: "telegram"}, map[string][]string{"telegram": {"restock"}}, `unknown event type "restock"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := subscribe([]Notifier{tt.notifier}, tt.subscriptions)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("subscribe() failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("subscribe() error = %v, want one containing %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscribedFiltersEvents(t *testing.T) {
+	r := &recorder{name: "telegram"}
+	notifiers, err := subscribe([]Notifier{r}, map[string][]string{"telegram": {"price_change", "removed"}})
+	if err != nil {
+		t.Fatalf("subscribe() failed: %v", err)
+	}
+
+	for _, eventType := range []models.EventType{models.EventNew, models.EventPriceChange, models.EventSale, models.EventRemoved} {
+		Dispatch(logger.Logger{}, notifiers, models.Event{Type: eventType, Product: models.Product{ID: "A"}})
+	}
+
+	var sent []models.EventType
+	for _, event := range r.events {
+		sent = append(sent, event.Type)
+	}
+	if len(sent) != 2 || sent[0] != models.EventPriceChange || sent[1] != models.EventRemoved {
+		t.Errorf("sent %v, want only the price change and removal", sent)
+	}
+}
@@ -0,0 +1,149 @@
+package ntfy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/message"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// Message priorities as defined by the ntfy protocol
+const (
+	priorityLow     = "2"
+	priorityDefault = "3"
+	priorityHigh    = "4"
+)
+
+type Topic struct {
+	url        string
+	httpClient *customhttp.Client
+	dryRun     bool
+	retry      customhttp.RetryPolicy
+	template   *message.Template
+}
+
+func New(serverURL, topic string) *Topic {
+	return &Topic{
+		url:        fmt.Sprintf("%s/%s", strings.TrimRight(serverURL, "/"), topic),
+		httpClient: customhttp.NewClient(),
+	}
+}
+
+func (t *Topic) Name() string {
+	return "ntfy"
+}
+
+// SetMessageTemplate replaces the message body, the product title by
+// default, with the rendered template.
+func (t *Topic) SetMessageTemplate(template *message.Template) {
+	t.template = template
+}
+
+// SetDryRun makes the topic log rendered messages instead of publishing them.
+func (t *Topic) SetDryRun(enabled bool) {
+	t.dryRun = enabled
+}
+
+// SetTimeout changes how long a request to the ntfy server may take.
+func (t *Topic) SetTimeout(timeout time.Duration) {
+	t.httpClient.SetTimeout(timeout)
+}
+
+// SetRetryPolicy retries messages that failed with a network error or a 5xx
+// response.
+func (t *Topic) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	t.retry = policy
+}
+
+func (t *Topic) SendProduct(product models.Product) error {
+	return t.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (t *Topic) SendEvent(event models.Event) error {
+	product := event.Product
+
+	title, tags, priority := "New UniFi Product", "tada", priorityDefault
+	switch event.Type {
+	case models.EventUpcoming:
+		title, tags = "Upcoming UniFi Product", "soon"
+	case models.EventAvailable:
+		title, tags, priority = "UniFi Product Now Available", "white_check_mark", priorityHigh
+	case models.EventRemoved:
+		title, tags, priority = "UniFi Product Removed", "no_entry", priorityLow
+	case models.EventBackInStock:
+		title, tags, priority = "UniFi Product Back in Stock", "repeat", priorityHigh
+	case models.EventPriceChange:
+		title, tags, priority = "UniFi Price Change", "moneybag", priorityHigh
+	case models.EventSale:
+		title, tags, priority = "UniFi Sale", "label", priorityHigh
+	case models.EventUpdated:
+		title, tags = "UniFi Product Updated", "pencil2"
+	case models.EventInStock:
+		title, tags, priority = "UniFi Product Now In Stock", "rocket", priorityHigh
+	case models.EventSoldOut:
+		title, tags, priority = "UniFi Product Sold Out", "no_entry_sign", priorityLow
+	case models.EventNewSubCategory:
+		title, tags, priority = "New UniFi Subcategory", "card_index_dividers", priorityHigh
+	}
+
+	headers := map[string]string{
+		"Title":    title,
+		"Click":    event.URL(),
+		"Tags":     tags,
+		"Priority": priority,
+	}
+	if product.Thumbnail.URL != "" {
+		headers["Attach"] = product.Thumbnail.URL
+	}
+
+	body := event.Title()
+	if t.template != nil {
+		rendered, err := t.template.Render(event)
+		if err != nil {
+			logger.Warning().Err(err).Str("id", product.ID).Msg("Failed to render message template, using the default body")
+		} else {
+			body = rendered
+		}
+	}
+
+	if t.dryRun {
+		logger.Info().
+			Str("body", body).
+			Interface("headers", headers).
+			Msg("Dry run, skipping ntfy message")
+		return nil
+	}
+
+	return t.retry.Do(t.Name(), func() error {
+		return t.send(body, headers)
+	})
+}
+
+// send posts a single message to the topic.
+func (t *Topic) send(body string, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, t.url, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create ntfy request: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send ntfy message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &customhttp.StatusError{Service: "ntfy", StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
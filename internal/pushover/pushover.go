@@ -0,0 +1,236 @@
+package pushover
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+const (
+	apiURL = "https://api.pushover.net/1/messages.json"
+
+	// Pushover rejects attachments larger than 2.5MB
+	maxAttachmentSize = 2621440
+
+	priorityNormal = 0
+	priorityHigh   = 1
+)
+
+type Client struct {
+	appToken   string
+	userKey    string
+	httpClient *customhttp.Client
+	dryRun     bool
+	retry      customhttp.RetryPolicy
+
+	mu         sync.Mutex
+	limitReset time.Time
+}
+
+func New(appToken, userKey string) *Client {
+	return &Client{
+		appToken:   appToken,
+		userKey:    userKey,
+		httpClient: customhttp.NewClient(),
+	}
+}
+
+func (c *Client) Name() string {
+	return "pushover"
+}
+
+// SetDryRun makes the client log rendered messages instead of sending them.
+func (c *Client) SetDryRun(enabled bool) {
+	c.dryRun = enabled
+}
+
+// SetTimeout changes how long a request to the Pushover API may take.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.httpClient.SetTimeout(timeout)
+}
+
+// SetRetryPolicy retries messages that failed with a network error or a 5xx
+// response.
+func (c *Client) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	c.retry = policy
+}
+
+func (c *Client) SendProduct(product models.Product) error {
+	return c.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (c *Client) SendEvent(event models.Event) error {
+	product := event.Product
+
+	title, priority := "New UniFi Product", priorityNormal
+	switch event.Type {
+	case models.EventUpcoming:
+		title = "Upcoming UniFi Product"
+	case models.EventAvailable:
+		title, priority = "UniFi Product Now Available", priorityHigh
+	case models.EventRemoved:
+		title = "UniFi Product Removed"
+	case models.EventBackInStock:
+		title = "UniFi Product Back in Stock"
+	case models.EventPriceChange:
+		title = "UniFi Price Change"
+		if priceDropped(event) {
+			priority = priorityHigh
+		}
+	case models.EventSale:
+		title, priority = "UniFi Sale", priorityHigh
+	case models.EventUpdated:
+		title = "UniFi Product Updated"
+	case models.EventInStock:
+		title, priority = "UniFi Product Now In Stock", priorityHigh
+	case models.EventSoldOut:
+		title = "UniFi Product Sold Out"
+	case models.EventNewSubCategory:
+		title, priority = "New UniFi Subcategory", priorityHigh
+	}
+
+	storeURL := event.URL()
+
+	if c.dryRun {
+		logger.Info().
+			Str("title", title).
+			Str("message", event.Title()).
+			Str("url", storeURL).
+			Int("priority", priority).
+			Msg("Dry run, skipping Pushover message")
+		return nil
+	}
+
+	c.mu.Lock()
+	limitReset := c.limitReset
+	c.mu.Unlock()
+	if time.Now().Before(limitReset) {
+		return fmt.Errorf("pushover message limit reached until %s", limitReset.Format(time.RFC3339))
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"token":     c.appToken,
+		"user":      c.userKey,
+		"title":     title,
+		"message":   event.Title(),
+		"url":       storeURL,
+		"url_title": "Open in Store",
+		"priority":  strconv.Itoa(priority),
+	}
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return fmt.Errorf("failed to write pushover field: %w", err)
+		}
+	}
+
+	if image, err := c.fetchImage(product.Thumbnail.URL); err != nil {
+		logger.Warning().Err(err).Str("id", product.ID).Msg("Failed to fetch thumbnail, sending without image")
+	} else {
+		part, err := writer.CreateFormFile("attachment", path.Base(product.Thumbnail.URL))
+		if err != nil {
+			return fmt.Errorf("failed to create pushover attachment: %w", err)
+		}
+		if _, err := part.Write(image); err != nil {
+			return fmt.Errorf("failed to write pushover attachment: %w", err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish pushover body: %w", err)
+	}
+
+	return c.retry.Do(c.Name(), func() error {
+		req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return fmt.Errorf("failed to create pushover request: %w", err)
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send pushover message: %w", err)
+		}
+		defer resp.Body.Close()
+
+		c.trackLimit(resp)
+
+		if resp.StatusCode != http.StatusOK {
+			return &customhttp.StatusError{Service: "pushover", StatusCode: resp.StatusCode}
+		}
+
+		return nil
+	})
+}
+
+// trackLimit honors Pushover's rate-limit headers by pausing sends until the
+// limit resets once no messages remain.
+func (c *Client) trackLimit(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Limit-App-Remaining"))
+	if err != nil || (remaining > 0 && resp.StatusCode != http.StatusTooManyRequests) {
+		return
+	}
+
+	reset, err := strconv.ParseInt(resp.Header.Get("X-Limit-App-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.limitReset = time.Unix(reset, 0)
+	c.mu.Unlock()
+
+	logger.Warning().Time("reset", c.limitReset).Msg("Pushover message limit reached, pausing notifications")
+}
+
+func (c *Client) fetchImage(url string) ([]byte, error) {
+	if url == "" {
+		return nil, fmt.Errorf("product has no thumbnail")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(image) > maxAttachmentSize {
+		return nil, fmt.Errorf("thumbnail exceeds %d bytes", maxAttachmentSize)
+	}
+
+	return image, nil
+}
+
+func priceDropped(event models.Event) bool {
+	for _, variant := range event.Variants {
+		if variant.DisplayPrice.Amount < event.OldPrices[variant.ID] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Unifi Store Monitor</title>
+<style>
+body { font-family: sans-serif; margin: 24px; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 32px; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px 8px; text-align: left; vertical-align: middle; }
+th a { color: inherit; }
+img { width: 48px; }
+</style>
+</head>
+<body>
+<h1>Unifi Store Monitor</h1>
+
+<h2>Recent events</h2>
+{{if .Events}}
+<table>
+<tr><th>Time</th><th>Event</th><th>Product</th><th>Category</th><th>Old price</th><th>New price</th></tr>
+{{range .Events}}
+<tr><td>{{.Time}}</td><td>{{.Type}}</td><td>{{.Title}}</td><td>{{.Category}}</td><td>{{.OldPrice}}</td><td>{{.NewPrice}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No events yet.</p>
+{{end}}
+
+<h2>Catalog ({{len .Products}} products)</h2>
+<table>
+<tr>
+<th></th>
+<th><a href="?sort=title">Title</a></th>
+<th><a href="?sort=price">Price</a></th>
+<th><a href="?sort=first_seen">First seen</a></th>
+<th>Categories</th>
+</tr>
+{{range .Products}}
+<tr>
+<td>{{if .Thumbnail}}<img src="{{.Thumbnail}}" alt=""></td>{{else}}</td>{{end}}
+<td><a href="{{.URL}}">{{.Title}}</a></td>
+<td>{{.Price}}</td>
+<td>{{.FirstSeen}}</td>
+<td>{{.Categories}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+type dashboardProduct struct {
+	Title      string
+	URL        string
+	Thumbnail  string
+	Price      string
+	FirstSeen  string
+	Categories string
+
+	amount    int
+	firstSeen time.Time
+}
+
+type dashboardEvent struct {
+	Time     string
+	Type     models.EventType
+	Title    string
+	Category string
+	OldPrice string
+	NewPrice string
+}
+
+const dashboardTimeFormat = "2006-01-02 15:04"
+
+// handleDashboard renders the known catalog, sorted by the "sort" query
+// parameter (title, price or first_seen), and the recent events, newest
+// first.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var products []dashboardProduct
+	for _, product := range s.store.KnownProducts() {
+		p := dashboardProduct{
+			Title:      product.DisplayTitle(),
+			URL:        product.URL(),
+			Thumbnail:  product.Thumbnail.URL,
+			Price:      "N/A",
+			Categories: strings.Join(product.Categories, ", "),
+			firstSeen:  product.FirstSeen,
+		}
+		if len(product.Variants) > 0 {
+			cheapest := slices.MinFunc(product.Variants, func(a, b models.Variant) int {
+				return a.DisplayPrice.Amount - b.DisplayPrice.Amount
+			})
+			p.Price, p.amount = cheapest.Price(), cheapest.DisplayPrice.Amount
+		}
+		if !product.FirstSeen.IsZero() {
+			p.FirstSeen = product.FirstSeen.Local().Format(dashboardTimeFormat)
+		}
+		products = append(products, p)
+	}
+
+	switch r.URL.Query().Get("sort") {
+	case "title":
+		slices.SortFunc(products, func(a, b dashboardProduct) int {
+			return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		})
+	case "price":
+		slices.SortFunc(products, func(a, b dashboardProduct) int {
+			return a.amount - b.amount
+		})
+	default:
+		// Newest first
+		slices.SortFunc(products, func(a, b dashboardProduct) int {
+			return b.firstSeen.Compare(a.firstSeen)
+		})
+	}
+
+	history := s.store.RecentEvents()
+	events := make([]dashboardEvent, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		event := dashboardEvent{
+			Time:     entry.Time.Local().Format(dashboardTimeFormat),
+			Type:     entry.Type,
+			Title:    entry.Title,
+			Category: entry.Category,
+		}
+		if entry.OldPrice != nil {
+			event.OldPrice = models.FormatPrice(*entry.OldPrice, entry.Currency)
+		}
+		if entry.NewPrice != nil {
+			event.NewPrice = models.FormatPrice(*entry.NewPrice, entry.Currency)
+		}
+		events = append(events, event)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, struct {
+		Products []dashboardProduct
+		Events   []dashboardEvent
+	}{products, events})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to render dashboard")
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"all-unifi-monitor/internal/store"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// Server exposes the monitor's HTTP endpoints:
+//
+//	/         HTML dashboard of the catalog and recent events
+//	/metrics  Prometheus metrics
+//	/events   recent detection events as JSON
+//	/status   known products and last sweep statistics as JSON
+//	/stream   detection events as they happen, as Server-Sent Events
+//	/history/{id}  price history of a product as JSON, or CSV with ?format=csv
+//	/resync   POST, rebuilds the known products without alerting (admin)
+//	/reload-products  POST, reloads the known products from the products file (admin)
+type Server struct {
+	store      *store.UnifiStore
+	httpServer *http.Server
+	// adminToken guards the admin endpoints, which are disabled without it
+	adminToken string
+}
+
+func New(addr string, unifiStore *store.UnifiStore) *Server {
+	s := &Server{store: unifiStore}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/stream", s.handleStream)
+	mux.HandleFunc("/history/{id}", s.handleHistory)
+	mux.HandleFunc("/resync", s.handleResync)
+	mux.HandleFunc("/reload-products", s.handleReloadProducts)
+	mux.HandleFunc("/", s.handleDashboard)
+
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// SetAdminToken enables the admin endpoints for requests bearing token.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// Start serves requests in the background.
+func (s *Server) Start() {
+	go func() {
+		logger.Info().Str("addr", s.httpServer.Addr).Msg("Starting HTTP server")
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error().Err(err).Msg("HTTP server stopped")
+		}
+	}()
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.store.RecentEvents())
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.store.Status())
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	points, err := s.store.PriceHistory(r.PathValue("id"))
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to read price history")
+		http.Error(w, "failed to read price history", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		if points == nil {
+			points = []store.PricePoint{}
+		}
+		writeJSON(w, points)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		if err := store.WritePriceHistoryCSV(w, points); err != nil {
+			logger.Error().Err(err).Msg("Failed to write HTTP response")
+		}
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error().Err(err).Msg("Failed to write HTTP response")
+	}
+}
+
+// resyncResponse reports the outcome of a resync.
+type resyncResponse struct {
+	Seeded int    `json:"seeded"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) handleResync(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// A client giving up doesn't stop the resync halfway
+	seeded, err := s.store.Resync(context.WithoutCancel(r.Context()))
+	if err != nil {
+		logger.Error().Err(err).Int("seeded", seeded).Msg("Resync failed")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		writeJSON(w, resyncResponse{Seeded: seeded, Error: err.Error()})
+		return
+	}
+
+	logger.Info().Int("seeded", seeded).Msg("Resync complete")
+	writeJSON(w, resyncResponse{Seeded: seeded})
+}
+
+// reloadProductsResponse reports the outcome of reloading the products
+// file.
+type reloadProductsResponse struct {
+	Loaded int    `json:"loaded"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (s *Server) handleReloadProducts(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	loaded, err := s.store.ReloadProducts()
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to reload products file")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, reloadProductsResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, reloadProductsResponse{Loaded: loaded})
+}
+
+// authorized reports whether the request carries the admin token, answering
+// it otherwise. Admin endpoints are not found while no token is configured.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+const (
+	tempSuffix   = ".tmp"
+	backupSuffix = ".bak"
+)
+
+// errCorruptProducts is returned when a products file exists but can't be
+// decoded.
+var errCorruptProducts = errors.New("products file is corrupt")
+
+// replaceWithBackup moves the products file to its backup and the fully
+// written temporary file into its place. A crash between the two renames
+// leaves no products file, which is recovered from the backup on the next
+// start. Appends never change existing records, so the backup is only
+// refreshed when the file is rewritten.
+func replaceWithBackup(productsFile, tempPath string) error {
+	if err := os.Rename(productsFile, productsFile+backupSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to back up products file: %w", err)
+	}
+	if err := os.Rename(tempPath, productsFile); err != nil {
+		return fmt.Errorf("failed to replace products file: %w", err)
+	}
+	return nil
+}
+
+// removeStaleTempFile deletes a temporary file left behind by a rewrite
+// that was interrupted before its rename.
+func removeStaleTempFile(productsFile string) {
+	tempPath := productsFile + tempSuffix
+	if err := os.Remove(tempPath); err == nil {
+		logger.Warning().Str("file", tempPath).Msg("Removed temporary file left by an interrupted save")
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Error().Err(err).Str("file", tempPath).Msg("Failed to remove temporary file left by an interrupted save")
+	}
+}
+
+// restoreBackup reads the products kept from before the last rewrite, for
+// when the products file is missing or corrupt. It returns nil when there
+// is no usable backup.
+func (s *UnifiStore) restoreBackup() map[string]models.Product {
+	path := s.cfg.ProductsFile + backupSuffix
+	products, _, err := readProductsFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error().Err(err).Str("file", path).Msg("Failed to read products backup")
+		}
+		return nil
+	}
+	if products == nil {
+		return nil
+	}
+
+	logger.Warning().
+		Str("file", path).
+		Int("products", len(products)).
+		Msg("Restored known products from backup, the next sweep catches up on changes since the last rewrite without alerting")
+	return products
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// writeRecords writes products as one JSON record per line, followed by
+// tail.
+func writeRecords(t *testing.T, path string, tail string, products ...models.Product) {
+	t.Helper()
+
+	var data []byte
+	for _, product := range products {
+		line, err := json.Marshal(productRecord{Product: product})
+		if err != nil {
+			t.Fatalf("failed to encode product: %v", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(path, append(data, tail...), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestLoadTruncatedProductsFile(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "")
+
+	// The process died halfway through appending the last record
+	writeRecords(t, s.cfg.ProductsFile, `{"id":"C","title":"Prod`, testProduct("A", 100), testProduct("B", 200))
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 200), testProduct("C", 300))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+
+	// The complete records are kept and the lost one is found again
+	sent := r.sent()
+	if len(sent) != 1 || sent[0].Product.ID != "C" {
+		t.Errorf("sent %v, want a new product alert for C only", sent)
+	}
+}
+
+func TestRestoredBackupDoesNotAlert(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "")
+
+	// The backup is from the last rewrite, B was appended to the products
+	// file after it, which was then corrupted
+	writeRecords(t, s.cfg.ProductsFile+backupSuffix, "", testProduct("A", 100))
+	if err := os.WriteFile(s.cfg.ProductsFile, []byte("{not json\n{\"id\":\"B\"}\n"), 0644); err != nil {
+		t.Fatalf("failed to corrupt products file: %v", err)
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 200))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 0 {
+		t.Fatalf("sweep after restoring the backup sent %v, want no alerts", sent)
+	}
+
+	corrupt, _ := filepath.Glob(s.cfg.ProductsFile + ".corrupt-*")
+	if len(corrupt) != 1 {
+		t.Errorf("found %d copies of the corrupt file, want 1", len(corrupt))
+	}
+
+	// Alerts resume once the sweep caught up
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 200), testProduct("C", 300))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	sent := r.sent()
+	if len(sent) != 1 || sent[0].Product.ID != "C" {
+		t.Errorf("sent %v, want a new product alert for C only", sent)
+	}
+
+	// The restored products were written back with the ones caught up on
+	products, _, err := readProductsFile(s.cfg.ProductsFile)
+	if err != nil {
+		t.Fatalf("failed to read products file: %v", err)
+	}
+	for _, id := range []string{"A", "B"} {
+		if _, ok := products[id]; !ok {
+			t.Errorf("product %s is missing from the products file", id)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"all-unifi-monitor/internal/notifier"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// States of the circuit breaker, as shown by the status endpoint
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// circuitBreaker stops sweeping a store that keeps failing. After
+// breaker_threshold failed sweeps in a row it opens, and the store is only
+// probed every breaker_cooldown until a probe succeeds. It is guarded by the
+// store mutex.
+type circuitBreaker struct {
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// BreakerStatus is the state of the circuit breaker.
+type BreakerStatus struct {
+	State string `json:"state"`
+	// ConsecutiveFailures counts the failed sweeps since the last one that
+	// reached the store
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// OpenedAt is nil while the breaker is closed
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+}
+
+// storeDown reports whether a sweep failed because the store couldn't be
+// reached or refused to answer, as opposed to a partial failure or a change
+// in its pages.
+func storeDown(err error) bool {
+	return errors.Is(err, ErrNetwork) ||
+		errors.Is(err, ErrStoreUnavailable) ||
+		errors.Is(err, ErrChallengePage)
+}
+
+// recordBreaker counts the outcome of a sweep and opens the breaker once
+// breaker_threshold sweeps in a row failed because the store was down.
+func (s *UnifiStore) recordBreaker(err error) {
+	s.mutex.Lock()
+	if !storeDown(err) {
+		s.breaker.failures = 0
+		s.mutex.Unlock()
+		return
+	}
+
+	s.breaker.failures++
+	failures := s.breaker.failures
+	threshold := s.cfg.BreakerThreshold
+	if threshold <= 0 || failures < threshold || s.breaker.state == breakerOpen {
+		s.mutex.Unlock()
+		return
+	}
+
+	s.breaker.state = breakerOpen
+	s.breaker.openedAt = time.Now()
+	s.mutex.Unlock()
+
+	s.log.Error().
+		Err(err).
+		Int("failedSweeps", failures).
+		Dur("cooldown", s.cfg.BreakerCooldown).
+		Msg("Store keeps failing, pausing sweeps until it recovers")
+	notifier.Alert(s.log, s.notifiers, "Store unreachable",
+		fmt.Sprintf("The last %d sweeps failed because the store could not be reached (%s). "+
+			"Sweeps are paused and the store is checked every %s until it answers again.",
+			failures, err, s.cfg.BreakerCooldown))
+}
+
+// breakerOpen reports whether sweeps are paused by the breaker.
+func (s *UnifiStore) breakerOpen() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.breaker.state == breakerOpen
+}
+
+// probeStore requests the home page once while the breaker is open and
+// closes the breaker when it loads, which also refreshes the build ID. It
+// reports whether sweeps may resume.
+func (s *UnifiStore) probeStore(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	s.mutex.Lock()
+	s.breaker.state = breakerHalfOpen
+	s.mutex.Unlock()
+
+	log := logger.With("probe", logger.NewID())
+	err := s.fetchBuildID(log)
+
+	s.mutex.Lock()
+	if err != nil {
+		s.breaker.state = breakerOpen
+		s.mutex.Unlock()
+		log.Warning().Err(err).Dur("cooldown", s.cfg.BreakerCooldown).Msg("Store is still failing, sweeps stay paused")
+		return false
+	}
+
+	paused := time.Since(s.breaker.openedAt).Round(time.Second)
+	s.breaker = circuitBreaker{state: breakerClosed}
+	s.mutex.Unlock()
+
+	log.Info().Dur("paused", paused).Msg("Store is answering again, resuming sweeps")
+	notifier.Alert(log, s.notifiers, "Store reachable again",
+		fmt.Sprintf("The store answered again after sweeps were paused for %s.", paused))
+	return true
+}
+
+// breakerStatus returns the breaker's state for the status endpoint. Must
+// be called with the mutex held.
+func (s *UnifiStore) breakerStatus() BreakerStatus {
+	status := BreakerStatus{
+		State:               s.breaker.state,
+		ConsecutiveFailures: s.breaker.failures,
+	}
+	if status.State == "" {
+		status.State = breakerClosed
+	}
+	if s.breaker.state != breakerClosed && !s.breaker.openedAt.IsZero() {
+		openedAt := s.breaker.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
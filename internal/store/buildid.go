@@ -0,0 +1,27 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// buildIDExpired reports whether the cached build ID has to be fetched
+// before the next sweep, because there is none yet or build_id_ttl passed.
+func (s *UnifiStore) buildIDExpired() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.buildID == "" {
+		return true
+	}
+	return s.cfg.BuildIDTTL > 0 && time.Since(s.buildIDFetchedAt) >= s.cfg.BuildIDTTL
+}
+
+// buildIDMayHaveExpired reports whether a category request failed in a way
+// the store answers requests for an outdated build ID with.
+func (s *UnifiStore) buildIDMayHaveExpired(err error) bool {
+	if s.replayDir != "" {
+		return false
+	}
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrSchemaChanged)
+}
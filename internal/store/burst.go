@@ -0,0 +1,34 @@
+package store
+
+import (
+	"fmt"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/notifier"
+)
+
+// capAlerts returns the alerts of a sweep that may be sent. Past
+// max_alerts_per_sweep none of them are sent individually: that many at once
+// is more likely a lost products file or a store glitch than a real launch,
+// so a single summary is sent in their place. The products are still saved,
+// so the next sweep is quiet.
+func (s *UnifiStore) capAlerts(alerts []models.Event) []models.Event {
+	limit := s.cfg.MaxAlertsPerSweep
+	if limit <= 0 || len(alerts) <= limit {
+		return alerts
+	}
+
+	s.mutex.Lock()
+	newProducts := s.stats.newFound
+	s.mutex.Unlock()
+
+	s.log.Warning().
+		Int("alerts", len(alerts)).
+		Int("newProducts", newProducts).
+		Int("limit", limit).
+		Msg("Too many alerts in one sweep, sending a summary instead")
+	notifier.Alert(s.log, s.notifiers, "Alerts suppressed",
+		fmt.Sprintf("%d new products detected (likely resync). The sweep's %d alerts exceed max_alerts_per_sweep (%d) and were not sent individually.",
+			newProducts, len(alerts), limit))
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAlertCap(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "stateless: true\nmax_alerts_per_sweep: 2\n")
+
+	m.list("all-wifi", testProduct("A", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+
+	// Up to the cap every alert is sent
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 100), testProduct("C", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 2 {
+		t.Fatalf("sent %d alerts at the cap, want 2", len(sent))
+	}
+	if len(r.alerts) != 0 {
+		t.Fatalf("sent summaries %v at the cap, want none", r.alerts)
+	}
+
+	// Past the cap only the summary is sent, in any category
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 100), testProduct("C", 100), testProduct("D", 100), testProduct("E", 100))
+	m.list("all-switching", testProduct("F", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 2 {
+		t.Errorf("sent %d alerts in total, want only the 2 of the previous sweep", len(sent))
+	}
+	if len(r.alerts) != 1 || r.alerts[0] != "Alerts suppressed" {
+		t.Errorf("sent summaries %v, want a single one", r.alerts)
+	}
+
+	// The suppressed products are known, so the next sweep is quiet
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 2 || len(r.alerts) != 1 {
+		t.Errorf("quiet sweep sent %d alerts and %d summaries, want none", len(sent)-2, len(r.alerts)-1)
+	}
+}
+
+func TestAlertCapBatched(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "stateless: true\nmax_alerts_per_sweep: 2\nbatch_alerts: true\n")
+
+	m.list("all-wifi", testProduct("A", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 100), testProduct("C", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 2 {
+		t.Fatalf("sent batches %v, want one batch of 2", r.batches)
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 100), testProduct("C", 100), testProduct("D", 100), testProduct("E", 100), testProduct("F", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if len(r.batches) != 1 || len(r.alerts) != 1 {
+		t.Errorf("sent %d batches and %d summaries, want only a summary past the cap", len(r.batches)-1, len(r.alerts))
+	}
+}
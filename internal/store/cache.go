@@ -0,0 +1,24 @@
+package store
+
+import (
+	"strconv"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// bypassCache asks caches between the monitor and the store for a fresh
+// response, as configured by bypass_cache.
+func bypassCache(req *http.Request, mode string) {
+	switch mode {
+	case config.BypassCacheHeader:
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+	case config.BypassCacheQuery:
+		query := req.URL.Query()
+		query.Set("_", strconv.FormatInt(time.Now().UnixMilli(), 10))
+		req.URL.RawQuery = query.Encode()
+	}
+}
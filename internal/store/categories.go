@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// categoryLinkPattern matches the category links in the store navigation.
+var categoryLinkPattern = regexp.MustCompile(`/us/en/category/([a-z0-9-]+)`)
+
+// discoverCategories scrapes the category slugs linked from the store's home
+// page, in the order they first appear.
+func (s *UnifiStore) discoverCategories() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.HTTPTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.HomeURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var categories []string
+	seen := make(map[string]bool)
+	for _, match := range categoryLinkPattern.FindAllStringSubmatch(string(body), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			categories = append(categories, match[1])
+		}
+	}
+
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("no category links found")
+	}
+	return categories, nil
+}
+
+// refreshCategories replaces the configured categories with the ones
+// currently linked from the store, keeping the include and exclude filters.
+// The configured list is kept when discovery fails.
+func (s *UnifiStore) refreshCategories() {
+	discovered, err := s.discoverCategories()
+	if err != nil {
+		s.log.Warning().Err(err).Msg("Failed to discover categories, using the configured list")
+		return
+	}
+
+	categories := selectCategories(discovered, s.cfg.IncludeCategories, s.cfg.ExcludeCategories)
+	if len(categories) == 0 {
+		s.log.Warning().Msg("No discovered category passes the category filters, using the configured list")
+		return
+	}
+
+	s.log.Info().Strs("categories", categories).Msg("Discovered categories")
+	s.categories = categories
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	http "github.com/saucesteals/fhttp"
+
+	customhttp "all-unifi-monitor/internal/http"
+)
+
+// challengeMarkers appear in Cloudflare's challenge and block pages.
+var challengeMarkers = []string{"cf-chl", "challenge-platform", "Just a moment...", "Attention Required!"}
+
+// isChallengePage reports whether an HTML page is a bot challenge rather
+// than the store's home page.
+func isChallengePage(header http.Header, body string) bool {
+	if header.Get("cf-mitigated") == "challenge" {
+		return true
+	}
+	for _, marker := range challengeMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTML reports whether a response that should be JSON is an HTML page,
+// going by its Content-Type or, failing that, a leading '<'. The body is
+// only peeked at, so it can still be decoded afterwards.
+func isHTML(header http.Header, body *bufio.Reader) bool {
+	if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && mediaType == "text/html" {
+		return true
+	}
+
+	// A short body returns what's available together with an error
+	start, _ := body.Peek(512)
+	return bytes.HasPrefix(bytes.TrimSpace(start), []byte("<"))
+}
+
+// peekedBody reads from the buffered reader and closes the underlying body.
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// refreshOnChallenge gives client a fresh browser fingerprint when err is a
+// challenge page and reports whether the request is worth retrying. Each
+// client is refreshed at most once per sweep, so a store that keeps
+// challenging is left to the usual backoff.
+func (s *UnifiStore) refreshOnChallenge(client *customhttp.Client, err error) bool {
+	if !errors.Is(err, ErrChallengePage) || s.refreshedClients[client] {
+		return false
+	}
+	if s.refreshedClients == nil {
+		s.refreshedClients = make(map[*customhttp.Client]bool)
+	}
+	s.refreshedClients[client] = true
+
+	previous, version, err := client.RefreshFingerprint()
+	if err != nil {
+		s.log.Error().Err(err).Msg("Failed to refresh browser fingerprint")
+		return false
+	}
+
+	s.log.Warning().
+		Str("previousVersion", previous).
+		Str("version", version).
+		Msg("Store returned a challenge page, refreshed the browser fingerprint and retrying")
+	return true
+}
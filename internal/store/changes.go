@@ -0,0 +1,234 @@
+package store
+
+import (
+	"time"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// compareKnown diffs a freshly fetched product against its stored record and
+// returns the resulting events. The stored record is replaced when anything
+// changed so each change is only reported once. Must be called with the mutex
+// held.
+func (s *UnifiStore) compareKnown(product models.Product) []models.Event {
+	known := s.knownProducts[product.ID]
+	// These are tracked by the monitor, not returned by the store
+	product.Categories = known.Categories
+	product.FirstSeen = known.FirstSeen
+	product.LastSeen = known.LastSeen
+	relisted := s.cfg.RemovalThreshold > 0 && s.missingPasses[product.ID] >= s.cfg.RemovalThreshold
+	delete(s.missingPasses, product.ID)
+
+	var events []models.Event
+	// Variants are matched by ID throughout, as the store doesn't keep them
+	// in a fixed order
+	changed := !sameVariantIDs(known.Variants, product.Variants)
+	// The price and title change of a launch are part of its available
+	// event
+	launched := s.isUpcoming(known) && !s.isUpcoming(product)
+
+	if launched {
+		s.log.Info().
+			Str("id", product.ID).
+			Str("title", product.Title).
+			Msg("Upcoming product now available")
+		events = append(events, models.Event{Type: models.EventAvailable, Product: product})
+		changed = true
+	}
+
+	if event, ok := checkVariants(known, product, relisted); ok {
+		s.log.Info().
+			Str("id", product.ID).
+			Str("title", product.Title).
+			Int("addedVariants", len(event.Variants)).
+			Msg("Product back in stock")
+		events = append(events, event)
+		changed = true
+	}
+
+	if change, ok := priceChanges(known, product); ok {
+		s.log.Info().
+			Str("id", product.ID).
+			Str("title", product.Title).
+			Int("changedVariants", len(change.Variants)).
+			Msg("Price changed")
+		changed = true
+
+		// A sale is announced on top of the price change
+		if !launched {
+			events = append(events, change)
+			if event, ok := s.checkSale(change); ok {
+				events = append(events, event)
+			}
+		}
+	}
+
+	if event, ok := availabilityChanges(known, product); ok {
+		changed = true
+
+		if s.cfg.WatchAvailability && len(event.Variants) > 0 {
+			s.log.Info().
+				Str("id", product.ID).
+				Str("title", product.Title).
+				Int("variants", len(event.Variants)).
+				Msg("Product in stock")
+			events = append(events, event)
+		}
+	}
+
+	if product.Title != known.Title || product.ShortDescription != known.ShortDescription {
+		s.log.Info().
+			Str("id", product.ID).
+			Str("oldTitle", known.Title).
+			Str("title", product.Title).
+			Msg("Product details changed")
+		changed = true
+
+		if s.cfg.WatchMetadataChanges && !launched {
+			previous := known
+			events = append(events, models.Event{Type: models.EventUpdated, Product: product, Previous: &previous})
+		}
+	}
+
+	if changed {
+		s.pendingPrices = append(s.pendingPrices, pricePoints(known, product, time.Now())...)
+		s.knownProducts[product.ID] = product
+		s.pendingProducts = append(s.pendingProducts, productRecord{Product: product})
+	}
+
+	return events
+}
+
+// sameVariantIDs reports whether both lists hold the same variant IDs,
+// ignoring their order and repeated entries.
+func sameVariantIDs(a, b []models.Variant) bool {
+	ids := make(map[string]bool, len(a))
+	for _, variant := range a {
+		ids[variant.ID] = true
+	}
+
+	seen := make(map[string]bool, len(b))
+	for _, variant := range b {
+		if !ids[variant.ID] {
+			return false
+		}
+		seen[variant.ID] = true
+	}
+	return len(seen) == len(ids)
+}
+
+// checkVariants returns a back-in-stock event listing the variants that
+// weren't in the stored record, or every variant when the product reappears
+// after being reported as removed.
+func checkVariants(known, product models.Product, relisted bool) (models.Event, bool) {
+	knownVariants := make(map[string]bool, len(known.Variants))
+	for _, variant := range known.Variants {
+		knownVariants[variant.ID] = true
+	}
+
+	var added []models.Variant
+	for _, variant := range product.Variants {
+		if relisted || !knownVariants[variant.ID] {
+			added = append(added, variant)
+		}
+	}
+
+	if len(added) == 0 {
+		return models.Event{}, false
+	}
+
+	return models.Event{Type: models.EventBackInStock, Product: product, Variants: added}, true
+}
+
+// priceChanges returns a price change event listing every variant whose
+// price differs between the two snapshots of a product.
+func priceChanges(previous, current models.Product) (models.Event, bool) {
+	oldPrices := make(map[string]int, len(previous.Variants))
+	for _, variant := range previous.Variants {
+		oldPrices[variant.ID] = variant.DisplayPrice.Amount
+	}
+
+	event := models.Event{
+		Type:      models.EventPriceChange,
+		Product:   current,
+		OldPrices: make(map[string]int),
+	}
+	for _, variant := range current.Variants {
+		oldPrice, ok := oldPrices[variant.ID]
+		if !ok || oldPrice == variant.DisplayPrice.Amount {
+			continue
+		}
+		event.Variants = append(event.Variants, variant)
+		event.OldPrices[variant.ID] = oldPrice
+	}
+
+	return event, len(event.Variants) > 0
+}
+
+// checkSale narrows a price change down to the variants whose price dropped
+// by at least min_discount_percent compared to the last known price.
+func (s *UnifiStore) checkSale(change models.Event) (models.Event, bool) {
+	if s.cfg.MinDiscountPercent <= 0 {
+		return models.Event{}, false
+	}
+
+	sale := models.Event{
+		Type:      models.EventSale,
+		Product:   change.Product,
+		OldPrices: make(map[string]int),
+	}
+	for _, variant := range change.Variants {
+		oldPrice := change.OldPrices[variant.ID]
+		if oldPrice <= 0 {
+			continue
+		}
+
+		discount := float64(oldPrice-variant.DisplayPrice.Amount) / float64(oldPrice) * 100
+		if discount < s.cfg.MinDiscountPercent {
+			continue
+		}
+
+		sale.Variants = append(sale.Variants, variant)
+		sale.OldPrices[variant.ID] = oldPrice
+	}
+
+	if len(sale.Variants) == 0 {
+		return models.Event{}, false
+	}
+
+	s.log.Info().
+		Str("id", sale.Product.ID).
+		Str("title", sale.Product.Title).
+		Msg("Product on sale")
+
+	return sale, true
+}
+
+// availabilityChanges reports whether the stock status of the product or any
+// of its variants changed since the known record. The returned event lists
+// the variants that went from coming soon to in stock.
+func availabilityChanges(known, product models.Product) (models.Event, bool) {
+	changed := known.Status != product.Status
+
+	knownStatus := make(map[string]models.Availability, len(known.Variants))
+	for _, variant := range known.Variants {
+		knownStatus[variant.ID] = variant.Availability()
+	}
+
+	var released []models.Variant
+	for _, variant := range product.Variants {
+		previous, ok := knownStatus[variant.ID]
+		if !ok {
+			continue
+		}
+		current := variant.Availability()
+		if previous != current {
+			changed = true
+		}
+		if previous == models.AvailabilityComingSoon && current == models.AvailabilityInStock {
+			released = append(released, variant)
+		}
+	}
+
+	return models.Event{Type: models.EventInStock, Product: product, Variants: released}, changed
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"all-unifi-monitor/internal/models"
+)
+
+func TestSameVariantIDs(t *testing.T) {
+	variants := func(ids ...string) []models.Variant {
+		var list []models.Variant
+		for _, id := range ids {
+			list = append(list, models.Variant{ID: id})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name string
+		a, b []models.Variant
+		want bool
+	}{
+		{"same order", variants("a", "b", "c"), variants("a", "b", "c"), true},
+		{"reordered", variants("a", "b", "c"), variants("c", "a", "b"), true},
+		{"repeated", variants("a", "b"), variants("b", "a", "b"), true},
+		{"added", variants("a", "b"), variants("b", "a", "c"), false},
+		{"removed", variants("a", "b", "c"), variants("c", "a"), false},
+		{"replaced", variants("a", "b"), variants("a", "c"), false},
+		{"both empty", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameVariantIDs(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameVariantIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderedVariantsAreNotChanges(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "stateless: true\nmin_discount_percent: 1\nwatch_availability: true\nwatch_metadata_changes: true\n")
+
+	product := testProduct("A", 0)
+	product.Variants = []models.Variant{
+		{ID: "A-v1", Status: "Available", DisplayPrice: models.DisplayPrice{Amount: 30000, Currency: "USD"}},
+		{ID: "A-v2", Status: "SoldOut", DisplayPrice: models.DisplayPrice{Amount: 20000, Currency: "USD"}},
+		{ID: "A-v3", Status: "Available", DisplayPrice: models.DisplayPrice{Amount: 10000, Currency: "USD"}},
+	}
+	m.list("all-wifi", product)
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+
+	// Compared by position, every variant would look cheaper or restocked
+	slices.Reverse(product.Variants)
+	m.list("all-wifi", product)
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+
+	if sent := r.sent(); len(sent) != 0 {
+		t.Errorf("sent %v for reordered variants, want no alerts", sent)
+	}
+	if history := s.RecentEvents(); len(history) != 0 {
+		t.Errorf("recorded %v for reordered variants, want no events", history)
+	}
+}
+
+func TestPriceChangeEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		want     []models.EventType
+	}{
+		{"price change", "", []models.EventType{models.EventPriceChange}},
+		{"sale", "min_discount_percent: 10\n", []models.EventType{models.EventPriceChange, models.EventSale}},
+		{"below the sale discount", "min_discount_percent: 60\n", []models.EventType{models.EventPriceChange}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockStore(t)
+			s, r := newTestStore(t, m, "stateless: true\n"+tt.settings)
+
+			m.list("all-wifi", testProduct("A", 10000))
+			if err := s.RunOnce(context.Background()); err != nil {
+				t.Fatalf("seeding sweep failed: %v", err)
+			}
+			m.list("all-wifi", testProduct("A", 8000))
+			if err := s.RunOnce(context.Background()); err != nil {
+				t.Fatalf("sweep failed: %v", err)
+			}
+
+			var sent, recorded []models.EventType
+			for _, event := range r.sent() {
+				sent = append(sent, event.Type)
+			}
+			for _, entry := range s.RecentEvents() {
+				recorded = append(recorded, entry.Type)
+			}
+			if !slices.Equal(sent, tt.want) {
+				t.Fatalf("sent %v, want %v", sent, tt.want)
+			}
+			if !slices.Equal(recorded, tt.want) {
+				t.Errorf("recorded %v, want %v", recorded, tt.want)
+			}
+
+			change := r.sent()[0]
+			if change.OldPrices["A-v1"] != 10000 || len(change.Variants) != 1 || change.Variants[0].DisplayPrice.Amount != 8000 {
+				t.Errorf("price change %+v, want A-v1 from 10000 to 8000", change)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"time"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// alertAllowed reports whether an alert about the event's product may be
+// sent and, if so, starts the product's alert_cooldown. Products filtered
+// out by title are never alerted. Otherwise new product alerts, including
+// upcoming products and their launch, are always allowed, and so are the
+// other changes found at the same time as the one that started the cooldown,
+// such as a price change and its sale. Must be called with the mutex held.
+func (s *UnifiStore) alertAllowed(log logger.Logger, event models.Event, now time.Time) bool {
+	id := event.Product.ID
+	if !s.titleFilter.Allows(event.Product.Title) {
+		log.Info().
+			Str("id", id).
+			Str("event", string(event.Type)).
+			Msg("Skipping notification, title filtered out")
+		return false
+	}
+	if last, ok := s.lastAlert[id]; ok && !isLaunch(event.Type) && last.Before(now) && now.Sub(last) < s.cfg.AlertCooldown {
+		log.Info().
+			Str("id", id).
+			Str("event", string(event.Type)).
+			Dur("remaining", s.cfg.AlertCooldown-now.Sub(last)).
+			Msg("Skipping notification, product is in its alert cooldown")
+		return false
+	}
+
+	s.startCooldown(id, now)
+	return true
+}
+
+// startCooldown starts the alert_cooldown of a product that is alerted
+// about. Must be called with the mutex held.
+func (s *UnifiStore) startCooldown(id string, now time.Time) {
+	s.lastAlert[id] = now
+}
+
+func isLaunch(eventType models.EventType) bool {
+	switch eventType {
+	case models.EventNew, models.EventUpcoming, models.EventAvailable:
+		return true
+	}
+	return false
+}
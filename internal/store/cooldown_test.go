@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+func TestAlertAllowed(t *testing.T) {
+	s := &UnifiStore{
+		cfg:       &config.Config{AlertCooldown: 10 * time.Minute},
+		lastAlert: make(map[string]time.Time),
+	}
+	now := time.Now()
+	product := models.Product{ID: "A", Title: "Product A"}
+	priceChange := models.Event{Type: models.EventPriceChange, Product: product}
+
+	s.startCooldown(product.ID, now)
+
+	// Changes found along with the alerted one are alerted too
+	if !s.alertAllowed(logger.Logger{}, models.Event{Type: models.EventSale, Product: product}, now) {
+		t.Error("sale found with the alerted change was not allowed")
+	}
+	if s.alertAllowed(logger.Logger{}, priceChange, now.Add(time.Minute)) {
+		t.Error("price change within the cooldown was allowed")
+	}
+	if !s.alertAllowed(logger.Logger{}, models.Event{Type: models.EventAvailable, Product: product}, now.Add(2*time.Minute)) {
+		t.Error("launch within the cooldown was not allowed")
+	}
+	// The launch restarted the cooldown
+	if s.alertAllowed(logger.Logger{}, priceChange, now.Add(11*time.Minute)) {
+		t.Error("price change within the restarted cooldown was allowed")
+	}
+	if !s.alertAllowed(logger.Logger{}, priceChange, now.Add(13*time.Minute)) {
+		t.Error("price change after the cooldown was not allowed")
+	}
+}
+
+func TestNewProductStartsCooldown(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "stateless: true\nalert_cooldown: 1h\nmin_discount_percent: 10\n")
+
+	m.list("all-wifi", testProduct("A", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 200))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 150))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+
+	sent := r.sent()
+	if len(sent) != 1 || sent[0].Type != models.EventNew || sent[0].Product.ID != "B" {
+		t.Fatalf("sent %v, want only the new product alert for B", sent)
+	}
+
+	// The skipped sale is still recorded
+	recorded := false
+	for _, entry := range s.RecentEvents() {
+		recorded = recorded || entry.Type == models.EventSale
+	}
+	if !recorded {
+		t.Error("skipped sale is missing from the history")
+	}
+}
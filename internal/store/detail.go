@@ -0,0 +1,172 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	http "github.com/saucesteals/fhttp"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// productDetailResponse is the part of a product page's data that the
+// category listing lacks.
+type productDetailResponse struct {
+	PageProps struct {
+		Product *struct {
+			Images []struct {
+				URL string `json:"url"`
+			} `json:"images"`
+			Specifications []models.Spec `json:"specifications"`
+			Variants       []struct {
+				ID     string `json:"id"`
+				SKU    string `json:"sku"`
+				Status string `json:"status"`
+			} `json:"variants"`
+			// Accessories list the products they work with under either
+			// name, as product references or plain titles
+			CompatibleProducts json.RawMessage `json:"compatibleProducts"`
+			Compatibility      json.RawMessage `json:"compatibility"`
+		} `json:"product"`
+	} `json:"pageProps"`
+}
+
+// compatibleProduct is a product reference in a compatibility list.
+type compatibleProduct struct {
+	Title string `json:"title"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+}
+
+// parseCompatibility reads a compatibility list of product references or
+// plain titles. Products on the storefront at path are linked by their slug.
+// Anything else yields no products, so an unexpected shape doesn't fail the
+// whole detail.
+func parseCompatibility(raw json.RawMessage, path string) []models.CompatibleProduct {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var compatible []models.CompatibleProduct
+
+	var references []compatibleProduct
+	if err := json.Unmarshal(raw, &references); err == nil {
+		for _, reference := range references {
+			title := reference.Title
+			if title == "" {
+				title = reference.Name
+			}
+			if title == "" {
+				continue
+			}
+			product := models.CompatibleProduct{Title: title}
+			if reference.Slug != "" {
+				product.URL = models.Product{Slug: reference.Slug, StorefrontPath: path}.URL()
+			}
+			compatible = append(compatible, product)
+		}
+		return compatible
+	}
+
+	var titles []string
+	if err := json.Unmarshal(raw, &titles); err == nil {
+		for _, title := range titles {
+			if title != "" {
+				compatible = append(compatible, models.CompatibleProduct{Title: title})
+			}
+		}
+	}
+	return compatible
+}
+
+// fetchProductPage decodes the page data of the product with the given slug
+// on the storefront at path, or the main storefront when path is empty, into
+// v. errProductNotFound is returned when the store has no such page. Must be
+// called without the mutex held.
+func (s *UnifiStore) fetchProductPage(path, slug string, v any) error {
+	if path == "" {
+		path = models.DefaultStorefrontPath
+	}
+
+	s.mutex.Lock()
+	buildID := s.buildID
+	s.mutex.Unlock()
+
+	url := fmt.Sprintf("%s/_next/data/%s/%s/products/%s.json?slug=%s", s.storeURL(), buildID, path, slug, slug)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: failed to fetch product: %w", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errProductNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: failed to decode response: %w", decodeErrorKind(err), err)
+	}
+	return nil
+}
+
+// fetchProductDetail fetches the details of the product with the given slug
+// on the storefront at path. Must be called without the mutex held.
+func (s *UnifiStore) fetchProductDetail(path, slug string) (*models.ProductDetail, error) {
+	if path == "" {
+		path = models.DefaultStorefrontPath
+	}
+
+	var response productDetailResponse
+	if err := s.fetchProductPage(path, slug, &response); err != nil {
+		return nil, err
+	}
+
+	product := response.PageProps.Product
+	if product == nil {
+		return nil, errProductNotFound
+	}
+
+	detail := &models.ProductDetail{Specs: product.Specifications}
+	detail.CompatibleWith = parseCompatibility(product.CompatibleProducts, path)
+	if len(detail.CompatibleWith) == 0 {
+		detail.CompatibleWith = parseCompatibility(product.Compatibility, path)
+	}
+	for _, image := range product.Images {
+		if image.URL != "" {
+			detail.Images = append(detail.Images, image.URL)
+		}
+	}
+	for _, variant := range product.Variants {
+		sku := variant.SKU
+		if sku == "" {
+			sku = variant.ID
+		}
+		detail.Stock = append(detail.Stock, models.Stock{SKU: sku, Status: variant.Status})
+	}
+	return detail, nil
+}
+
+// enrich adds the product page details to a new product event when
+// enrich_alerts is enabled. The alert is sent without them when the page
+// can't be fetched. Must be called without the mutex held.
+func (s *UnifiStore) enrich(event *models.Event) {
+	if !s.cfg.EnrichAlerts || s.replayDir != "" {
+		return
+	}
+
+	detail, err := s.fetchProductDetail(event.Product.StorefrontPath, event.Product.Slug)
+	if err != nil {
+		s.log.Warning().Err(err).Str("id", event.Product.ID).Msg("Failed to fetch product detail, sending the alert without it")
+		return
+	}
+	event.Detail = detail
+}
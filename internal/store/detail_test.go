@@ -0,0 +1,79 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"all-unifi-monitor/internal/models"
+)
+
+func TestFetchProductStorefrontPath(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "stateless: true\n")
+	s.buildID = testBuildID
+
+	uk := testProduct("UK1", 9900)
+	m.page("uk/en", uk.Slug, map[string]any{"pageProps": map[string]any{"product": uk}})
+	us := testProduct("US1", 19900)
+	m.page("us/en", us.Slug, map[string]any{"pageProps": map[string]any{"product": us}})
+
+	product, err := s.fetchProduct("uk/en", uk.Slug)
+	if err != nil {
+		t.Fatalf("fetchProduct() error = %v", err)
+	}
+	if product.ID != uk.ID || product.StorefrontPath != "uk/en" {
+		t.Errorf("fetchProduct() = %s on %q, want %s on uk/en", product.ID, product.StorefrontPath, uk.ID)
+	}
+
+	// An empty path is the main storefront
+	product, err = s.fetchProduct("", us.Slug)
+	if err != nil {
+		t.Fatalf("fetchProduct() error = %v", err)
+	}
+	if product.ID != us.ID {
+		t.Errorf("fetchProduct() = %s, want %s", product.ID, us.ID)
+	}
+
+	if _, err := s.fetchProduct("", uk.Slug); !errors.Is(err, errProductNotFound) {
+		t.Errorf("fetchProduct() of a product on another storefront error = %v, want %v", err, errProductNotFound)
+	}
+}
+
+func TestFetchProductDetail(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "stateless: true\n")
+	s.buildID = testBuildID
+
+	m.page("ca/en", "u7-pro", map[string]any{"pageProps": map[string]any{"product": map[string]any{
+		"images":             []map[string]string{{"url": "https://example.com/1.png"}, {"url": ""}},
+		"specifications":     []models.Spec{{Name: "Ports", Value: "1"}},
+		"variants":           []map[string]string{{"id": "v1", "status": "AVAILABLE"}, {"id": "v2", "sku": "U7-PRO-US", "status": "SOLD_OUT"}},
+		"compatibleProducts": []map[string]string{{"title": "PoE Injector", "slug": "poe"}},
+	}}})
+	m.page("ca/en", "empty", map[string]any{"pageProps": map[string]any{}})
+
+	detail, err := s.fetchProductDetail("ca/en", "u7-pro")
+	if err != nil {
+		t.Fatalf("fetchProductDetail() error = %v", err)
+	}
+	if len(detail.Images) != 1 || detail.Images[0] != "https://example.com/1.png" {
+		t.Errorf("Images = %v, want the non-empty image", detail.Images)
+	}
+	if len(detail.Specs) != 1 {
+		t.Errorf("Specs = %v, want one spec", detail.Specs)
+	}
+	wantStock := []models.Stock{{SKU: "v1", Status: "AVAILABLE"}, {SKU: "U7-PRO-US", Status: "SOLD_OUT"}}
+	if len(detail.Stock) != len(wantStock) || detail.Stock[0] != wantStock[0] || detail.Stock[1] != wantStock[1] {
+		t.Errorf("Stock = %v, want %v", detail.Stock, wantStock)
+	}
+	if len(detail.CompatibleWith) != 1 || detail.CompatibleWith[0].URL != models.StoreURL+"/ca/en/products/poe" {
+		t.Errorf("CompatibleWith = %v, want the injector linked on the ca/en storefront", detail.CompatibleWith)
+	}
+
+	if _, err := s.fetchProductDetail("ca/en", "empty"); !errors.Is(err, errProductNotFound) {
+		t.Errorf("fetchProductDetail() of a page without product error = %v, want %v", err, errProductNotFound)
+	}
+	if _, err := s.fetchProductDetail("ca/en", "missing"); !errors.Is(err, errProductNotFound) {
+		t.Errorf("fetchProductDetail() of a missing page error = %v, want %v", err, errProductNotFound)
+	}
+}
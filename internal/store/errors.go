@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// schemaChangeDelay is the minimum wait after a sweep failed because
+	// the store's pages changed, which won't fix itself within seconds
+	schemaChangeDelay = 10 * time.Minute
+	// networkRetryDelay is the maximum wait after a sweep failed because of
+	// a network error, which is usually over quickly
+	networkRetryDelay = 5 * time.Second
+	// challengeDelay is the minimum wait after the store answered with a
+	// bot challenge, which retrying right away only prolongs
+	challengeDelay = 5 * time.Minute
+)
+
+// Errors returned by the store fetches, so callers can tell failures apart
+// with errors.Is.
+var (
+	// ErrBuildIDNotFound means the home page no longer contains the build
+	// ID in any of the known formats.
+	ErrBuildIDNotFound = errors.New("build ID not found")
+	// ErrStoreUnavailable means the store answered with a 5xx or 429 status.
+	ErrStoreUnavailable = errors.New("store unavailable")
+	// ErrNetwork means the request failed before a response was received,
+	// for example because of a timeout or a reset connection.
+	ErrNetwork = errors.New("network error")
+	// ErrNotFound means the store answered with 404, usually because the
+	// build ID or a category slug is stale.
+	ErrNotFound = errors.New("not found")
+	// ErrSchemaChanged means a response could not be decoded into the
+	// expected structure.
+	ErrSchemaChanged = errors.New("response schema changed")
+	// ErrChallengePage means the store answered with an HTML error or bot
+	// challenge page instead of the expected document, usually because
+	// requests are being rate limited or blocked.
+	ErrChallengePage = errors.New("received challenge page")
+)
+
+// decodeErrorKind tells a malformed or unexpected document apart from a body
+// that couldn't be read completely.
+func decodeErrorKind(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrSchemaChanged
+	}
+	return ErrNetwork
+}
+
+// sweepDelay returns how long to wait before the next sweep, and the jitter
+// to apply, depending on why the last sweep failed. While the breaker is
+// open that is breaker_cooldown.
+func (s *UnifiStore) sweepDelay(err error) (time.Duration, float64) {
+	delay, jitter := s.cfg.PollInterval, s.cfg.PollJitter
+
+	switch {
+	case s.breakerOpen():
+		delay = s.cfg.BreakerCooldown
+	case err == nil:
+	case retryAfter(err) > delay:
+		// Wait exactly as long as the store asked
+		delay, jitter = retryAfter(err), 0
+		s.log.Info().Dur("retryAfter", delay).Msg("Honoring server-requested backoff")
+	case errors.Is(err, ErrChallengePage):
+		delay = max(delay, challengeDelay)
+		s.log.Warning().Dur("delay", delay).Msg("Store returned a challenge page, backing off")
+	case errors.Is(err, ErrSchemaChanged) || errors.Is(err, ErrBuildIDNotFound):
+		delay = max(delay, schemaChangeDelay)
+		s.log.Warning().Dur("delay", delay).Msg("Store pages changed, backing off")
+	case errors.Is(err, ErrNetwork):
+		delay = min(delay, networkRetryDelay)
+	}
+
+	return delay, jitter
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"fmt"
+
+	"all-unifi-monitor/internal/notifier"
+)
+
+// checkEmptySweep counts consecutive complete sweeps that found no products
+// in any category. The store always lists products, so this usually means
+// its response format changed and the monitor can no longer see new
+// products. An alert is sent once when empty_sweep_threshold is reached and
+// again when products show up.
+func (s *UnifiStore) checkEmptySweep(total, categories int) {
+	threshold := s.cfg.EmptySweepThreshold
+
+	if total > 0 {
+		if threshold > 0 && s.emptySweeps >= threshold {
+			s.log.Info().Int("products", total).Msg("Products found again after empty sweeps")
+			notifier.Alert(s.log, s.notifiers, "Products found again",
+				fmt.Sprintf("The last sweep found %d products after %d empty sweeps.", total, s.emptySweeps))
+		}
+		s.emptySweeps = 0
+		return
+	}
+
+	s.emptySweeps++
+	s.log.Warning().Int("emptySweeps", s.emptySweeps).Msg("Sweep found no products in any category")
+
+	if threshold <= 0 || s.emptySweeps != threshold {
+		return
+	}
+
+	s.log.Error().
+		Int("emptySweeps", s.emptySweeps).
+		Msg("No products found in consecutive sweeps, the store format may have changed")
+	notifier.Alert(s.log, s.notifiers, "No products found",
+		fmt.Sprintf("The last %d sweeps found no products in any of the %d monitored categories, although every request succeeded. "+
+			"The store's response format has likely changed and new products won't be detected until the monitor is updated.",
+			s.emptySweeps, categories))
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"time"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// HistoryEntry is a detection event as kept in the recent event history.
+// Prices are in cents, like models.DisplayPrice.
+type HistoryEntry struct {
+	Time      time.Time        `json:"time"`
+	Type      models.EventType `json:"type"`
+	ProductID string           `json:"productId"`
+	Title     string           `json:"title"`
+	Category  string           `json:"category,omitempty"`
+	OldPrice  *int             `json:"oldPrice,omitempty"`
+	NewPrice  *int             `json:"newPrice,omitempty"`
+	Currency  string           `json:"currency,omitempty"`
+}
+
+// eventHistory is a fixed size ring buffer of the most recent events.
+type eventHistory struct {
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{entries: make([]HistoryEntry, max(size, 0))}
+}
+
+func (h *eventHistory) add(entry HistoryEntry) {
+	if len(h.entries) == 0 {
+		return
+	}
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the entries from oldest to newest.
+func (h *eventHistory) list() []HistoryEntry {
+	if !h.full {
+		return append([]HistoryEntry(nil), h.entries[:h.next]...)
+	}
+	return append(append([]HistoryEntry(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+func historyEntry(event models.Event) HistoryEntry {
+	entry := HistoryEntry{
+		Time:      event.DetectedAt,
+		Type:      event.Type,
+		ProductID: event.Product.ID,
+		Title:     event.Product.Title,
+		Category:  event.Category,
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if event.Type == models.EventNewSubCategory {
+		entry.Title = event.SubCategory
+	}
+	if len(event.Product.Variants) > 0 {
+		entry.Currency = event.Product.Variants[0].DisplayPrice.Currency
+	}
+
+	switch event.Type {
+	case models.EventPriceChange, models.EventSale:
+		if len(event.Variants) > 0 {
+			variant := event.Variants[0]
+			oldPrice, newPrice := event.OldPrices[variant.ID], variant.DisplayPrice.Amount
+			entry.OldPrice, entry.NewPrice = &oldPrice, &newPrice
+		}
+	case models.EventRemoved:
+		if len(event.Product.Variants) > 0 {
+			oldPrice := event.Product.Variants[0].DisplayPrice.Amount
+			entry.OldPrice = &oldPrice
+		}
+	default:
+		if len(event.Product.Variants) > 0 {
+			newPrice := event.Product.Variants[0].DisplayPrice.Amount
+			entry.NewPrice = &newPrice
+		}
+	}
+
+	return entry
+}
+
+// recordEvent adds event to the recent event history and the live feed.
+// Must be called with the mutex held.
+func (s *UnifiStore) recordEvent(event models.Event) {
+	entry := historyEntry(event)
+	s.history.add(entry)
+	s.publish(entry)
+}
+
+// KnownProducts returns a snapshot of every known product.
+func (s *UnifiStore) KnownProducts() []models.Product {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	products := make([]models.Product, 0, len(s.knownProducts))
+	for _, product := range s.knownProducts {
+		products = append(products, product)
+	}
+	return products
+}
+
+// RecentEvents returns the most recent detection events, oldest first.
+func (s *UnifiStore) RecentEvents() []HistoryEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.history.list()
+}
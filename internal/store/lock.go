@@ -0,0 +1,31 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errLocked is returned by tryLockFile when another process holds the lock.
+var errLocked = errors.New("file is locked by another process")
+
+// acquireInstanceLock takes an exclusive lock on a file next to the products
+// file and keeps it for the life of the process, so a second instance using
+// the same products file refuses to start.
+func acquireInstanceLock(productsFile string) (*os.File, error) {
+	path := productsFile + ".lock"
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := tryLockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("another instance is already using %s (lock held on %s)", productsFile, path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,20 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package store
+
+import "os"
+
+// File locking relies on flock, which isn't available on this platform, so
+// locks always succeed.
+
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func tryLockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// PricePoint is a variant's price as observed at a point in time.
+type PricePoint struct {
+	Time      time.Time `json:"time"`
+	ProductID string    `json:"productId"`
+	VariantID string    `json:"variantId"`
+	Amount    int       `json:"amount"`
+	Currency  string    `json:"currency,omitempty"`
+}
+
+// priceHistoryPath returns the file observed prices are appended to, kept
+// next to the products file.
+func priceHistoryPath(productsFile string) string {
+	return productsFile + ".prices"
+}
+
+// pricePoints returns a point for every variant of product that is new or
+// whose price differs from the known record.
+func pricePoints(known, product models.Product, now time.Time) []PricePoint {
+	knownPrices := make(map[string]int, len(known.Variants))
+	for _, variant := range known.Variants {
+		knownPrices[variant.ID] = variant.DisplayPrice.Amount
+	}
+
+	var points []PricePoint
+	for _, variant := range product.Variants {
+		if price, ok := knownPrices[variant.ID]; ok && price == variant.DisplayPrice.Amount {
+			continue
+		}
+		points = append(points, PricePoint{
+			Time:      now,
+			ProductID: product.ID,
+			VariantID: variant.ID,
+			Amount:    variant.DisplayPrice.Amount,
+			Currency:  variant.DisplayPrice.Currency,
+		})
+	}
+	return points
+}
+
+// savePriceHistory appends the pending price points to the price history
+// file. Must be called with the mutex held.
+func (s *UnifiStore) savePriceHistory() error {
+	if len(s.pendingPrices) == 0 {
+		return nil
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	for _, point := range s.pendingPrices {
+		if err := encoder.Encode(point); err != nil {
+			return fmt.Errorf("failed to encode price history: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(priceHistoryPath(s.cfg.ProductsFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open price history: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file, true); err != nil {
+		return fmt.Errorf("failed to lock price history: %w", err)
+	}
+	defer unlockFile(file)
+
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		return fmt.Errorf("failed to append price history: %w", err)
+	}
+
+	s.pendingPrices = s.pendingPrices[:0]
+	return nil
+}
+
+// ReadPriceHistory returns the saved price points of a product, oldest
+// first. It only reads the price history file, so it can be used while
+// another instance is monitoring.
+func ReadPriceHistory(productsFile, productID string) ([]PricePoint, error) {
+	file, err := os.Open(priceHistoryPath(productsFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open price history: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file, false); err != nil {
+		return nil, fmt.Errorf("failed to lock price history: %w", err)
+	}
+	defer unlockFile(file)
+
+	var points []PricePoint
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		var point PricePoint
+		// A torn last line from an interrupted write is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &point); err != nil {
+			continue
+		}
+		if point.ProductID == productID {
+			points = append(points, point)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read price history: %w", err)
+	}
+
+	return points, nil
+}
+
+// PriceHistory returns the price points of a product, including those not
+// saved yet.
+func (s *UnifiStore) PriceHistory(productID string) ([]PricePoint, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var points []PricePoint
+	if !s.cfg.Stateless {
+		var err error
+		if points, err = ReadPriceHistory(s.cfg.ProductsFile, productID); err != nil {
+			return nil, err
+		}
+	}
+	for _, point := range s.pendingPrices {
+		if point.ProductID == productID {
+			points = append(points, point)
+		}
+	}
+	return points, nil
+}
+
+// WritePriceHistoryCSV writes price points as CSV with a header row. Amounts
+// are in the currency's minor unit, such as cents.
+func WritePriceHistoryCSV(w io.Writer, points []PricePoint) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"time", "variant_id", "amount", "currency"}); err != nil {
+		return fmt.Errorf("failed to write price history: %w", err)
+	}
+	for _, point := range points {
+		record := []string{
+			point.Time.UTC().Format(time.RFC3339),
+			point.VariantID,
+			strconv.Itoa(point.Amount),
+			point.Currency,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write price history: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write price history: %w", err)
+	}
+	return nil
+}
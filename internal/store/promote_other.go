@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package store
+
+import "os"
+
+// SIGUSR1 isn't available on this platform, so a standby can only be
+// promoted by a reload.
+var promoteSignals []os.Signal
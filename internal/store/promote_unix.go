@@ -0,0 +1,11 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// promoteSignals promote a standby to primary.
+var promoteSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"time"
+)
+
+// pruneInterval is how often the known products are checked against
+// prune_after.
+const pruneInterval = time.Hour
+
+// pruneStale drops products that haven't been listed for longer than
+// prune_after from the known products and rewrites the products file on the
+// next save. A pruned product that reappears is announced as new. It is
+// only called after a complete sweep so every listed product has a fresh
+// last seen time.
+func (s *UnifiStore) pruneStale() {
+	if s.cfg.PruneAfter <= 0 || time.Since(s.lastPrune) < pruneInterval {
+		return
+	}
+	s.lastPrune = time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cutoff := time.Now().Add(-s.cfg.PruneAfter)
+	pruned := 0
+	for id, product := range s.knownProducts {
+		if !product.LastSeen.Before(cutoff) {
+			continue
+		}
+
+		delete(s.knownProductIDs, id)
+		delete(s.knownProducts, id)
+		delete(s.missingPasses, id)
+		delete(s.lastAlert, id)
+		pruned++
+	}
+
+	if pruned == 0 {
+		return
+	}
+
+	// Rewrite rather than append deleted records so the file shrinks
+	s.needsRewrite = true
+	s.pendingProducts = s.pendingProducts[:0]
+	s.log.Info().
+		Int("pruned", pruned).
+		Dur("pruneAfter", s.cfg.PruneAfter).
+		Msg("Pruned products not seen in the store")
+}
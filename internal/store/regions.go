@@ -0,0 +1,43 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"all-unifi-monitor/internal/config"
+	customhttp "all-unifi-monitor/internal/http"
+)
+
+// region returns the store region the target belongs to, such as us or uk.
+func (t target) region() string {
+	region, _, _ := strings.Cut(strings.Trim(t.storefront.Path, "/"), "/")
+	return strings.ToLower(region)
+}
+
+// newRegionClients returns a client per region_proxies entry that sends
+// every request through that region's proxy.
+func newRegionClients(cfg *config.Config) (map[string]*customhttp.Client, error) {
+	clients := make(map[string]*customhttp.Client, len(cfg.RegionProxies))
+	for region, proxy := range cfg.RegionProxies {
+		client, err := customhttp.NewClientWithProxies([]string{proxy}, cfg.ProxyCooldown, customhttp.Options{
+			UserAgent:      cfg.UserAgent,
+			AcceptLanguage: cfg.AcceptLanguage,
+			Headers:        cfg.ExtraHeaders,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("region_proxies.%s: %w", region, err)
+		}
+		client.SetTimeout(cfg.HTTPTimeout)
+		clients[strings.ToLower(region)] = client
+	}
+	return clients, nil
+}
+
+// clientFor returns the client to fetch the target's listings with: the
+// proxy of its region when one is configured, the default client otherwise.
+func (s *UnifiStore) clientFor(t target) *customhttp.Client {
+	if client, ok := s.regionClients[t.region()]; ok {
+		return client
+	}
+	return s.httpClient
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"maps"
+	"slices"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/notifier"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// reload is a validated configuration waiting to be applied by Start.
+type reload struct {
+	cfg         *config.Config
+	categories  []string
+	notifiers   []notifier.Notifier
+	titleFilter *config.TitleFilter
+}
+
+// Reload validates cfg and schedules it to replace the live configuration
+// before the next sweep. Settings that are only read at startup keep their
+// current value and are logged as requiring a restart.
+func (s *UnifiStore) Reload(cfg *config.Config) error {
+	s.mutex.Lock()
+	current := s.cfg
+	s.mutex.Unlock()
+
+	for _, setting := range keepStartupSettings(current, cfg) {
+		logger.Warning().Str("setting", setting).Msg("Setting changed but requires a restart to take effect")
+	}
+
+	categories, err := configuredCategories(cfg)
+	if err != nil {
+		return err
+	}
+
+	notifiers, err := notifier.Backends(cfg)
+	if err != nil {
+		return err
+	}
+
+	titleFilter, err := cfg.TitleFilter()
+	if err != nil {
+		return err
+	}
+
+	// Only the latest reload matters
+	select {
+	case <-s.reloads:
+	default:
+	}
+	s.reloads <- &reload{cfg: cfg, categories: categories, notifiers: notifiers, titleFilter: titleFilter}
+
+	return nil
+}
+
+// applyPendingReload swaps in the configuration queued by Reload, if any.
+// Sweeps read the configuration without the mutex, so it waits for a resync
+// in progress to finish.
+func (s *UnifiStore) applyPendingReload() {
+	var r *reload
+	select {
+	case r = <-s.reloads:
+	default:
+		return
+	}
+
+	s.sweepMutex.Lock()
+	defer s.sweepMutex.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.cfg = r.cfg
+	s.titleFilter = r.titleFilter
+	// Discovered categories are only refreshed at startup
+	if !r.cfg.AutoDiscoverCategories {
+		s.categories = r.categories
+	}
+
+	// Keep collecting into the running digest and quiet hours so nothing
+	// gathered so far is lost
+	s.notifiers = notifier.Rewire(s.notifiers, r.notifiers)
+
+	logger.Info().Strs("categories", s.categories).Int("notifiers", len(s.notifiers)).Msg("Configuration reloaded")
+}
+
+// keepStartupSettings copies the settings that can't change while running
+// from current to next and returns the names of those that differed.
+func keepStartupSettings(current, next *config.Config) []string {
+	var changed []string
+	keep := func(name string, differs bool) {
+		if differs {
+			changed = append(changed, name)
+		}
+	}
+
+	keep("products_file", current.ProductsFile != next.ProductsFile)
+	next.ProductsFile = current.ProductsFile
+
+	keep("stateless", current.Stateless != next.Stateless)
+	next.Stateless = current.Stateless
+
+	keep("save_interval", current.SaveInterval != next.SaveInterval)
+	next.SaveInterval = current.SaveInterval
+
+	keep("compact_on_load", current.CompactOnLoad != next.CompactOnLoad)
+	next.CompactOnLoad = current.CompactOnLoad
+
+	keep("listen_addr", current.ListenAddr != next.ListenAddr)
+	next.ListenAddr = current.ListenAddr
+
+	keep("proxies", !slices.Equal(current.Proxies, next.Proxies))
+	next.Proxies = current.Proxies
+
+	keep("proxy_cooldown", current.ProxyCooldown != next.ProxyCooldown)
+	next.ProxyCooldown = current.ProxyCooldown
+
+	keep("max_requests_per_second", current.MaxRequestsPerSecond != next.MaxRequestsPerSecond)
+	next.MaxRequestsPerSecond = current.MaxRequestsPerSecond
+
+	keep("region_proxies", !maps.Equal(current.RegionProxies, next.RegionProxies))
+	next.RegionProxies = current.RegionProxies
+
+	keep("socks5_proxy", current.SOCKS5Proxy != next.SOCKS5Proxy)
+	next.SOCKS5Proxy = current.SOCKS5Proxy
+
+	keep("http_timeout", current.HTTPTimeout != next.HTTPTimeout)
+	next.HTTPTimeout = current.HTTPTimeout
+
+	keep("user_agent", current.UserAgent != next.UserAgent)
+	next.UserAgent = current.UserAgent
+
+	keep("accept_language", current.AcceptLanguage != next.AcceptLanguage)
+	next.AcceptLanguage = current.AcceptLanguage
+
+	keep("extra_headers", !maps.Equal(current.ExtraHeaders, next.ExtraHeaders))
+	next.ExtraHeaders = current.ExtraHeaders
+
+	keep("event_history_size", current.EventHistorySize != next.EventHistorySize)
+	next.EventHistorySize = current.EventHistorySize
+
+	// A standby is promoted by a reload, but a primary can't step down
+	keep("role", current.Role == config.RolePrimary && next.Role != config.RolePrimary)
+	if current.Role == config.RolePrimary {
+		next.Role = current.Role
+	}
+
+	keep("admin_token", current.AdminToken != next.AdminToken)
+	next.AdminToken = current.AdminToken
+
+	keep("notify_mode", current.NotifyMode != next.NotifyMode)
+	next.NotifyMode = current.NotifyMode
+
+	keep("digest_interval", current.DigestInterval != next.DigestInterval)
+	next.DigestInterval = current.DigestInterval
+
+	keep("quiet_hours_start", current.QuietHoursStart != next.QuietHoursStart)
+	next.QuietHoursStart = current.QuietHoursStart
+
+	keep("quiet_hours_end", current.QuietHoursEnd != next.QuietHoursEnd)
+	next.QuietHoursEnd = current.QuietHoursEnd
+
+	keep("quiet_hours_timezone", current.QuietHoursTimezone != next.QuietHoursTimezone)
+	next.QuietHoursTimezone = current.QuietHoursTimezone
+
+	keep("watchlist", !slices.Equal(current.Watchlist, next.Watchlist))
+	next.Watchlist = current.Watchlist
+
+	keep("watch_interval", current.WatchInterval != next.WatchInterval)
+	next.WatchInterval = current.WatchInterval
+
+	keep("auto_discover_categories", current.AutoDiscoverCategories != next.AutoDiscoverCategories)
+	next.AutoDiscoverCategories = current.AutoDiscoverCategories
+
+	return changed
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestReloadDuringResync(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "stateless: true\n")
+	m.list("all-wifi", testProduct("A", 100))
+
+	s.mutex.Lock()
+	current := *s.cfg
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 5 {
+			if _, err := s.Resync(context.Background()); err != nil {
+				t.Errorf("resync failed: %v", err)
+			}
+		}
+	}()
+
+	// The sweep loop applies reloads while the HTTP server runs a resync
+	for i := range 5 {
+		cfg := current
+		cfg.Categories = []string{"all-wifi"}
+		cfg.MinDiscountPercent = float64(i)
+		if err := s.Reload(&cfg); err != nil {
+			t.Fatalf("reload failed: %v", err)
+		}
+		s.applyPendingReload()
+	}
+	wg.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !slices.Equal(s.categories, []string{"all-wifi"}) {
+		t.Errorf("categories = %v after reloading, want [all-wifi]", s.categories)
+	}
+}
+
+func TestReloadDuringReloadProducts(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "")
+	m.list("all-wifi", testProduct("A", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+
+	s.mutex.Lock()
+	current := *s.cfg
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 5 {
+			if loaded, err := s.ReloadProducts(); err != nil || loaded != 1 {
+				t.Errorf("ReloadProducts() = %d, %v, want 1 product", loaded, err)
+			}
+		}
+	}()
+
+	for i := range 5 {
+		cfg := current
+		cfg.MinDiscountPercent = float64(i)
+		if err := s.Reload(&cfg); err != nil {
+			t.Fatalf("reload failed: %v", err)
+		}
+		s.applyPendingReload()
+	}
+	wg.Wait()
+}
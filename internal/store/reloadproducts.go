@@ -0,0 +1,65 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"all-unifi-monitor/pkg/logger"
+)
+
+// ReloadProducts replaces the known products with the contents of the
+// products file, so products removed from it by hand are announced again. It
+// waits for a sweep in progress to finish and returns how many products were
+// loaded. Changes not saved yet are appended to the file first, which keeps
+// the edits.
+func (s *UnifiStore) ReloadProducts() (int, error) {
+	s.mutex.Lock()
+	cfg := s.cfg
+	s.mutex.Unlock()
+
+	if cfg.Stateless {
+		return 0, fmt.Errorf("there is no products file to reload in stateless mode")
+	}
+
+	s.sweepMutex.Lock()
+	defer s.sweepMutex.Unlock()
+
+	// Loading the products file later would be a second reload
+	s.loadOnce.Do(s.loadKnownProducts)
+
+	s.mutex.Lock()
+	standby, needsRewrite := s.standby, s.needsRewrite
+	s.mutex.Unlock()
+
+	if !standby {
+		// A rewrite replaces the file with the products known in memory
+		if needsRewrite {
+			return 0, fmt.Errorf("the products file is due to be rewritten, reload it after the next save")
+		}
+		if err := s.Flush(); err != nil {
+			return 0, fmt.Errorf("failed to save pending changes: %w", err)
+		}
+	}
+
+	products, info, err := readProductsFile(cfg.ProductsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("products file %s not found", cfg.ProductsFile)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read products file: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.setKnownProducts(products)
+	// Products that are missing from the listings start counting again
+	s.missingPasses = make(map[string]int)
+	if !standby {
+		s.needsRewrite = info.legacy
+	}
+
+	logger.Info().Int("knownProducts", len(s.knownProducts)).Msg("Reloaded known products from the products file")
+	return len(s.knownProducts), nil
+}
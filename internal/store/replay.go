@@ -0,0 +1,41 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	customhttp "all-unifi-monitor/internal/http"
+)
+
+// SetHTTPClient replaces the client the store is requested with, for
+// example with one that trusts a local test server. Categories with a region
+// proxy keep their own client.
+func (s *UnifiStore) SetHTTPClient(client *customhttp.Client) {
+	s.httpClient = client
+}
+
+// SetReplayDir makes sweeps read captured category listings from dir
+// instead of requesting them from the store. Everything else, including
+// change detection and notifications, runs as usual.
+func (s *UnifiStore) SetReplayDir(dir string) {
+	s.replayDir = dir
+}
+
+// openReplay opens the captured listing of a target. Main store categories
+// are read from <dir>/<category>.json and storefront categories from
+// <dir>/<storefront name>/<category>.json, in the format of the store's
+// en.json responses.
+func openReplay(dir string, t target) (io.ReadCloser, error) {
+	path := filepath.Join(dir, t.category+".json")
+	if t.storefront.Name != "" {
+		path = filepath.Join(dir, t.storefront.Name, t.category+".json")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open captured response: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// Resync replaces the known products with the current catalog without
+// alerting, to re-baseline after the products file was cleared or migrated.
+// It waits for a sweep in progress to finish and returns how many products
+// were seeded. When some categories fail, the others are seeded and the next
+// sweep seeds the rest, also without alerting.
+func (s *UnifiStore) Resync(ctx context.Context) (int, error) {
+	s.sweepMutex.Lock()
+	defer s.sweepMutex.Unlock()
+
+	// Loading the products file later would bring back the old products
+	s.loadOnce.Do(s.loadKnownProducts)
+
+	s.mutex.Lock()
+	if s.standby {
+		s.mutex.Unlock()
+		return 0, fmt.Errorf("a standby can't resync, promote it first")
+	}
+	s.knownProductIDs = make(map[string]bool)
+	s.knownProducts = make(map[string]models.Product)
+	s.missingPasses = make(map[string]int)
+	s.pendingProducts = nil
+	s.initialized = false
+	// The products file is written anew from the seeded products
+	s.needsRewrite = true
+	s.mutex.Unlock()
+
+	logger.Info().Msg("Resyncing known products from the store without alerting")
+	err := s.runOnce(ctx)
+
+	s.mutex.Lock()
+	seeded := len(s.knownProducts)
+	s.mutex.Unlock()
+
+	return seeded, err
+}
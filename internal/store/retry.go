@@ -0,0 +1,224 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/saucesteals/fhttp"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// statusError is returned when the store responds with an unexpected HTTP
+// status code. RetryAfter is set when a 429 or 503 response asked us to wait.
+type statusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+// Is classifies the status code as ErrStoreUnavailable or ErrNotFound.
+func (e *statusError) Is(target error) bool {
+	switch target {
+	case ErrStoreUnavailable:
+		return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	err := &statusError{StatusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return err
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: a number of
+// seconds or an HTTP date. It returns 0 when the value is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// retryAfter returns the backoff requested by the server for err, if any.
+func retryAfter(err error) time.Duration {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// isRetryable reports whether err is likely transient. Timeouts, connection
+// resets and 5xx/429 responses are retried; other 4xx responses and decode
+// errors are treated as permanent.
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrStoreUnavailable) || errors.Is(err, ErrNetwork) {
+		return true
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return false
+	}
+
+	return customhttp.IsNetworkError(err)
+}
+
+// retryPolicy bounds how often and for how long a request is retried.
+type retryPolicy struct {
+	maxRetries int
+	// maxBackoff caps the wait between two attempts
+	maxBackoff time.Duration
+	// maxElapsed stops retrying once this much time has passed since the
+	// first attempt. Zero means no limit.
+	maxElapsed time.Duration
+}
+
+func (s *UnifiStore) retryPolicy() retryPolicy {
+	return retryPolicy{
+		maxRetries: s.cfg.MaxRetries,
+		maxBackoff: s.cfg.MaxBackoff,
+		maxElapsed: s.cfg.MaxElapsedTime,
+	}
+}
+
+// backoffDuration returns 2^attempt seconds, capped at maxBackoff, of which
+// the second half is random jitter so retries from several requests don't
+// hit the store at the same moment.
+func backoffDuration(attempt int, maxBackoff time.Duration) time.Duration {
+	backoff := maxBackoff
+	if attempt < 32 {
+		backoff = min(time.Duration(1<<attempt)*time.Second, maxBackoff)
+	}
+	return backoff/2 + time.Duration(rand.Int64N(int64(backoff)/2+1))
+}
+
+// sleepWithJitter waits for base randomly adjusted by up to ±jitterPct (0.2
+// means ±20%) so polling doesn't follow an easily fingerprinted cadence. It
+// returns early with false when ctx is cancelled.
+func sleepWithJitter(ctx context.Context, base time.Duration, jitterPct float64) bool {
+	delay := base
+	if jitterPct > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * jitterPct * float64(base))
+	}
+
+	logger.Info().Msgf("Sleeping for %s...", delay.Round(time.Second))
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, or the
+// policy's retries or time budget have been used, backing off between
+// attempts. The last error is returned.
+func retry(operation string, policy retryPolicy, log logger.Logger, fn func() error) error {
+	start := time.Now()
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if !isRetryable(err) || attempt >= policy.maxRetries {
+			return err
+		}
+
+		backoff := backoffDuration(attempt, policy.maxBackoff)
+		if requested := retryAfter(err); requested > 0 {
+			backoff = requested
+			log.Info().
+				Str("operation", operation).
+				Dur("retryAfter", backoff).
+				Msg("Honoring server-requested backoff")
+		}
+
+		if policy.maxElapsed > 0 && time.Since(start)+backoff > policy.maxElapsed {
+			log.Warning().
+				Err(err).
+				Str("operation", operation).
+				Dur("maxElapsed", policy.maxElapsed).
+				Msg("Retry budget exhausted, giving up")
+			return err
+		}
+
+		log.Warning().
+			Err(err).
+			Str("operation", operation).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Msg("Request failed, retrying")
+		time.Sleep(backoff)
+	}
+}
+
+func (s *UnifiStore) fetchBuildIDWithRetry(policy retryPolicy) error {
+	// Captured responses don't need a build ID
+	if s.replayDir != "" {
+		return nil
+	}
+
+	fetch := func() error {
+		return retry("fetchBuildID", policy, s.log, func() error {
+			return s.fetchBuildID(s.log.With("request", logger.NewID()))
+		})
+	}
+
+	err := fetch()
+	if s.refreshOnChallenge(s.httpClient, err) {
+		err = fetch()
+	}
+	return err
+}
+
+func (s *UnifiStore) fetchProductsWithRetry(t target, policy retryPolicy) ([]models.Product, []models.SubCategory, error) {
+	var products []models.Product
+	var subCategories []models.SubCategory
+	fetch := func() error {
+		return retry("fetchProducts", policy, s.log, func() error {
+			var err error
+			products, subCategories, err = s.fetchProducts(t, s.log.With("request", logger.NewID()))
+			return err
+		})
+	}
+
+	err := fetch()
+	if s.replayDir == "" && s.refreshOnChallenge(s.clientFor(t), err) {
+		err = fetch()
+	}
+	return products, subCategories, err
+}
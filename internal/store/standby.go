@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+)
+
+// runStandby keeps the known products in sync with the products file the
+// primary writes until the standby is promoted, by a reload setting role to
+// primary or by a promotion signal. A requested promotion is retried every
+// standby_refresh_interval until the primary releases the products file. It
+// returns false when ctx is cancelled first.
+func (s *UnifiStore) runStandby(ctx context.Context) bool {
+	promote := make(chan os.Signal, 1)
+	if len(promoteSignals) > 0 {
+		signal.Notify(promote, promoteSignals...)
+		defer signal.Stop(promote)
+	}
+
+	logger.Info().Str("file", s.cfg.ProductsFile).Msg("Running as standby, notifications are disabled until promoted")
+	refreshErr := s.refreshKnownProducts()
+
+	requested := false
+	for {
+		s.mutex.Lock()
+		interval := s.cfg.StandbyRefreshInterval
+		s.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-promote:
+			logger.Info().Msg("Received promotion signal")
+			requested = true
+		case <-time.After(interval):
+		}
+
+		s.applyPendingReload()
+		s.mutex.Lock()
+		role := s.cfg.Role
+		s.mutex.Unlock()
+		if !requested && role == config.RolePrimary {
+			logger.Info().Msg("Role changed to primary by a reload")
+			requested = true
+		}
+
+		if !requested {
+			refreshErr = s.refreshKnownProducts()
+			continue
+		}
+		if s.promote(refreshErr) {
+			return true
+		}
+	}
+}
+
+// promote takes over alerting once the primary released its lock on the
+// products file. The products are read one last time so nothing the primary
+// saved before stopping is announced again. When that fails, the products
+// loaded last are kept and written back on the next save.
+func (s *UnifiStore) promote(refreshErr error) bool {
+	lock, err := acquireInstanceLock(s.cfg.ProductsFile)
+	if err != nil {
+		logger.Warning().Err(err).Msg("Can't promote to primary yet, retrying")
+		return false
+	}
+
+	if err := s.refreshKnownProducts(); err != nil {
+		refreshErr = err
+	}
+
+	s.mutex.Lock()
+	s.instanceLock = lock
+	s.standby = false
+	s.needsRewrite = refreshErr != nil
+	known := len(s.knownProducts)
+	s.mutex.Unlock()
+
+	// The known products are loaded already
+	s.loadOnce.Do(func() {})
+
+	logger.Info().Int("knownProducts", known).Msg("Promoted to primary, alerts are now enabled")
+	return true
+}
+
+// refreshKnownProducts replaces the known products with the contents of the
+// products file. A missing file leaves them unchanged.
+func (s *UnifiStore) refreshKnownProducts() error {
+	products, _, err := readProductsFile(s.cfg.ProductsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		logger.Warning().Err(err).Msg("Failed to reload products file, keeping the products loaded last")
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.setKnownProducts(products)
+	return nil
+}
+
+// setKnownProducts replaces the known products with products read from the
+// products file. Must be called with the mutex held.
+func (s *UnifiStore) setKnownProducts(products map[string]models.Product) {
+	now := time.Now()
+	known := make(map[string]models.Product, len(products))
+	ids := make(map[string]bool, len(products))
+	for id, product := range products {
+		if product.LastSeen.IsZero() {
+			product.LastSeen = now
+		}
+		known[id] = product
+		ids[id] = true
+	}
+
+	s.knownProducts = known
+	s.knownProductIDs = ids
+	if len(known) > 0 {
+		s.initialized = true
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import "time"
+
+// sweepStats describes recent sweeps for the status endpoint. It is guarded
+// by the store mutex.
+type sweepStats struct {
+	lastSweep    time.Time
+	lastDuration time.Duration
+	// newProducts counts the new products the last sweep found and
+	// newFound those of the sweep in progress
+	newProducts int
+	newFound    int
+	// categoryErrors counts failed fetches per category since startup
+	categoryErrors map[string]int
+}
+
+// Status is a summary of the monitor's state for quick health checks.
+type Status struct {
+	KnownProducts int `json:"knownProducts"`
+	Categories    int `json:"categories"`
+	// LastSweep is nil until the first sweep has finished
+	LastSweep            *time.Time     `json:"lastSweep"`
+	LastSweepDuration    string         `json:"lastSweepDuration"`
+	LastSweepNewProducts int            `json:"lastSweepNewProducts"`
+	CategoryErrors       map[string]int `json:"categoryErrors"`
+	Breaker              BreakerStatus  `json:"breaker"`
+}
+
+// Status returns the number of known products and statistics of the last
+// sweep.
+func (s *UnifiStore) Status() Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := Status{
+		KnownProducts:        len(s.knownProducts),
+		Categories:           len(s.categories),
+		LastSweepNewProducts: s.stats.newProducts,
+		CategoryErrors:       make(map[string]int, len(s.stats.categoryErrors)),
+		Breaker:              s.breakerStatus(),
+	}
+	if !s.stats.lastSweep.IsZero() {
+		lastSweep := s.stats.lastSweep
+		status.LastSweep = &lastSweep
+		status.LastSweepDuration = s.stats.lastDuration.Round(time.Millisecond).String()
+	}
+	for category, count := range s.stats.categoryErrors {
+		status.CategoryErrors[category] = count
+	}
+	return status
+}
+
+// recordSweep stores when the last sweep started, how long it took and how
+// many new products it found.
+func (s *UnifiStore) recordSweep(started time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.lastSweep = started
+	s.stats.lastDuration = time.Since(started)
+	s.stats.newProducts = s.stats.newFound
+}
+
+// recordCategoryError counts a failed fetch of the target. Must be called
+// with the mutex held.
+func (s *UnifiStore) recordCategoryError(t target) {
+	if s.stats.categoryErrors == nil {
+		s.stats.categoryErrors = make(map[string]int)
+	}
+
+	name := t.category
+	if t.storefront.Name != "" {
+		name = t.key()
+	}
+	s.stats.categoryErrors[name]++
+}
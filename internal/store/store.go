@@ -5,11 +5,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
@@ -17,36 +22,202 @@ import (
 	http "github.com/saucesteals/fhttp"
 
 	"all-unifi-monitor/internal/config"
-	"all-unifi-monitor/internal/discord"
 	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/metrics"
 	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/notifier"
 	"all-unifi-monitor/pkg/logger"
 )
 
-var buildIDPattern = regexp.MustCompile(`https://[^/]+/_next/static/([a-zA-Z0-9]+)/_ssgManifest\.js`)
+// buildIDStrategies are tried in order to find the Next.js build ID in the
+// store's home page, so a change to the asset URLs doesn't stop the monitor.
+var buildIDStrategies = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"ssgManifest", regexp.MustCompile(`https://[^/]+/_next/static/([a-zA-Z0-9]+)/_ssgManifest\.js`)},
+	{"buildManifest", regexp.MustCompile(`/_next/static/([a-zA-Z0-9_-]+)/_buildManifest\.js`)},
+	{"nextData", regexp.MustCompile(`"buildId"\s*:\s*"([a-zA-Z0-9_-]+)"`)},
+}
+
+// maxRecordSize bounds a single line of the products file.
+const maxRecordSize = 1024 * 1024
+
+// extractBuildID returns the build ID found in html and the name of the
+// strategy that found it.
+func extractBuildID(html string) (buildID, strategy string, ok bool) {
+	for _, candidate := range buildIDStrategies {
+		if matches := candidate.pattern.FindStringSubmatch(html); len(matches) >= 2 {
+			return matches[1], candidate.name, true
+		}
+	}
+	return "", "", false
+}
 
 type UnifiStore struct {
-	cfg             *config.Config
-	httpClient      *customhttp.Client
-	discord         *discord.Webhook
-	baseURL         string
-	categories      []string
-	knownProductIDs map[string]bool
-	knownProducts   map[string]models.Product
-	mutex           sync.Mutex
-	initialized     bool
-	pendingProducts []models.Product
-}
-
-func New(cfg *config.Config) *UnifiStore {
+	cfg        *config.Config
+	httpClient *customhttp.Client
+	// regionClients send the listings of a region through its proxy
+	regionClients map[string]*customhttp.Client
+	notifiers     []notifier.Notifier
+	// titleFilter is the compiled title_include_patterns and
+	// title_exclude_patterns
+	titleFilter *config.TitleFilter
+	buildID     string
+	// buildIDFetchedAt is when buildID was read from the home page
+	buildIDFetchedAt time.Time
+	categories       []string
+	knownProductIDs  map[string]bool
+	knownProducts    map[string]models.Product
+	mutex            sync.Mutex
+	// sweepMutex keeps a resync or a configuration reload from running
+	// alongside a sweep
+	sweepMutex sync.Mutex
+	// refreshedClients got a fresh browser fingerprint during the current
+	// sweep. It is only used by the sweep.
+	refreshedClients map[*customhttp.Client]bool
+	loadOnce         sync.Once
+	discoverOnce     sync.Once
+	initialized      bool
+	pendingProducts  []productRecord
+	pendingPrices    []PricePoint
+	// log carries the correlation ID of the current sweep. It is only used
+	// from the sweep goroutine.
+	log logger.Logger
+	// emptySweeps counts consecutive complete sweeps without any products
+	emptySweeps int
+	breaker     circuitBreaker
+	// lastPrune is when prune_after was last applied
+	lastPrune     time.Time
+	needsRewrite  bool
+	missingPasses map[string]int
+	// subCategories holds the subcategory names listed so far per target
+	subCategories map[string]map[string]bool
+	// lastAlert is when an alert was last sent per product ID
+	lastAlert    map[string]time.Time
+	stats        sweepStats
+	instanceLock *os.File
+	// standby is set until a standby is promoted to primary
+	standby bool
+	history *eventHistory
+	// subscribers receive every recorded event for the live feed
+	subscribers map[chan HistoryEntry]struct{}
+	thumbnails  *thumbnails
+	// replayDir replaces store requests with captured responses when set
+	replayDir string
+	reloads   chan *reload
+}
+
+func New(cfg *config.Config) (*UnifiStore, error) {
+	categories, err := configuredCategories(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stateless runs don't touch the disk, so they need neither the
+	// products file directory nor the instance lock
+	var instanceLock *os.File
+	if !cfg.Stateless {
+		// Relative paths resolve against the working directory, which is /
+		// under systemd, so make sure the configured location exists
+		if dir := filepath.Dir(cfg.ProductsFile); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create products file directory: %w", err)
+			}
+		}
+
+		// A standby shares the file the primary holds the lock on
+		if cfg.Role == config.RolePrimary {
+			instanceLock, err = acquireInstanceLock(cfg.ProductsFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	httpClient, err := customhttp.NewClientWithProxies(cfg.Proxies, cfg.ProxyCooldown, customhttp.Options{
+		UserAgent:      cfg.UserAgent,
+		AcceptLanguage: cfg.AcceptLanguage,
+		Headers:        cfg.ExtraHeaders,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpClient.SetTimeout(cfg.HTTPTimeout)
+	if cfg.SOCKS5Proxy != "" {
+		if err := httpClient.SetSOCKS5Proxy(cfg.SOCKS5Proxy); err != nil {
+			return nil, err
+		}
+	}
+
+	regionClients, err := newRegionClients(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every store request shares one limiter, whichever client sends it
+	if cfg.MaxRequestsPerSecond > 0 {
+		limiter := customhttp.NewRateLimiter(cfg.MaxRequestsPerSecond)
+		httpClient.SetRateLimiter(limiter)
+		for _, client := range regionClients {
+			client.SetRateLimiter(limiter)
+		}
+	}
+
+	titleFilter, err := cfg.TitleFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	notifiers, err := notifier.FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(notifiers) == 0 {
+		logger.Warning().Msg("No notifiers configured, new products will only be logged")
+	}
+
 	return &UnifiStore{
 		cfg:             cfg,
-		httpClient:      customhttp.NewClient(),
-		discord:         discord.New(cfg.DiscordWebhookURL),
-		categories:      defaultCategories(),
+		httpClient:      httpClient,
+		regionClients:   regionClients,
+		notifiers:       notifiers,
+		titleFilter:     titleFilter,
+		categories:      categories,
 		knownProductIDs: make(map[string]bool),
 		knownProducts:   make(map[string]models.Product),
+		missingPasses:   make(map[string]int),
+		lastAlert:       make(map[string]time.Time),
+		subCategories:   make(map[string]map[string]bool),
+		instanceLock:    instanceLock,
+		standby:         cfg.Role == config.RoleStandby,
+		history:         newEventHistory(cfg.EventHistorySize),
+		subscribers:     make(map[chan HistoryEntry]struct{}),
+		thumbnails:      newThumbnails(cfg.HTTPTimeout),
+		reloads:         make(chan *reload, 1),
+	}, nil
+}
+
+// configuredCategories returns the categories to sweep according to cfg and
+// checks that category_webhooks only names known categories.
+func configuredCategories(cfg *config.Config) ([]string, error) {
+	known := defaultCategories()
+	if len(cfg.Categories) > 0 {
+		known = cfg.Categories
+	}
+
+	categories, err := filterCategories(known, cfg.IncludeCategories, cfg.ExcludeCategories)
+	if err != nil {
+		return nil, err
+	}
+
+	for category := range cfg.CategoryWebhooks {
+		if !slices.Contains(known, category) && !cfg.AutoDiscoverCategories {
+			return nil, fmt.Errorf("unknown category %q in category_webhooks", category)
+		}
 	}
+
+	return categories, nil
 }
 
 func defaultCategories() []string {
@@ -63,157 +234,902 @@ func defaultCategories() []string {
 	}
 }
 
+// filterCategories restricts the known categories to the include list (when
+// set) and then drops anything in the exclude list. Unknown slugs are rejected
+// so a typo doesn't silently disable a category.
+func filterCategories(known, include, exclude []string) ([]string, error) {
+	isKnown := make(map[string]bool, len(known))
+	for _, category := range known {
+		isKnown[category] = true
+	}
+
+	for _, category := range append(append([]string{}, include...), exclude...) {
+		if !isKnown[category] {
+			return nil, fmt.Errorf("unknown category %q", category)
+		}
+	}
+
+	categories := selectCategories(known, include, exclude)
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("category filters exclude every category")
+	}
+
+	return categories, nil
+}
+
+// selectCategories applies the include and exclude lists to known without
+// validating them.
+func selectCategories(known, include, exclude []string) []string {
+	included := make(map[string]bool, len(include))
+	for _, category := range include {
+		included[category] = true
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, category := range exclude {
+		excluded[category] = true
+	}
+
+	var categories []string
+	for _, category := range known {
+		if len(include) > 0 && !included[category] {
+			continue
+		}
+		if excluded[category] {
+			continue
+		}
+		categories = append(categories, category)
+	}
+	return categories
+}
+
 func (s *UnifiStore) loadKnownProducts() {
-	logger.Info().Msg("Loading known products...")
-	file, err := os.Open(s.cfg.ProductsFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			logger.Info().Msg("Products.json file not found, creating new file")
-			file, err = os.Create(s.cfg.ProductsFile)
-			if err != nil {
-				logger.Error().Err(err).Msg("Failed to create products.json file")
-				return
+	if s.cfg.Stateless {
+		return
+	}
+
+	logger.Info().Str("file", s.cfg.ProductsFile).Msg("Loading known products...")
+	removeStaleTempFile(s.cfg.ProductsFile)
+
+	products, info, err := readProductsFile(s.cfg.ProductsFile)
+	restored := false
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, errCorruptProducts):
+		if errors.Is(err, errCorruptProducts) {
+			logger.Error().Err(err).Msg("Failed to decode products file")
+			s.backupCorruptFile()
+		}
+
+		products = s.restoreBackup()
+		if products == nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logger.Info().Msg("Products file not found, creating new file")
+				if file, err := os.Create(s.cfg.ProductsFile); err != nil {
+					logger.Error().Err(err).Msg("Failed to create products file")
+				} else {
+					file.Close()
+				}
+			} else {
+				logger.Warning().Msg("No usable backup, known products will be rebuilt from the next sweep without alerting")
 			}
-			file.Close()
-			s.initialized = false
 			return
 		}
-		logger.Error().Err(err).Msg("Failed to load products.json file")
+		// Write the restored products back to the products file
+		info.legacy = true
+		restored = true
+	default:
+		logger.Error().Err(err).Msg("Failed to load products file")
 		return
 	}
+
+	// An empty file has nothing to seed from
+	if products == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, product := range products {
+		// Records saved before last seen was tracked start the prune_after
+		// window now
+		if product.LastSeen.IsZero() {
+			product.LastSeen = now
+		}
+		s.knownProductIDs[product.ID] = true
+		s.knownProducts[product.ID] = product
+	}
+	logger.Info().Msgf("Loaded %d known products", len(s.knownProductIDs))
+	// The backup lacks the changes appended since the last rewrite, so the
+	// next sweep records them like a first sweep, without alerting
+	s.initialized = !restored
+
+	// Convert files written as a single JSON array, or restored from the
+	// backup, on the next save
+	s.needsRewrite = info.legacy
+
+	if info.superseded > 0 {
+		compact := s.cfg.CompactOnLoad && info.needsCompaction(len(products))
+		logger.Info().
+			Int("superseded", info.superseded).
+			Int("redundant", info.redundant).
+			Bool("compact", compact).
+			Msg("Products file has records replaced by later ones, only the latest of each product was loaded")
+		if compact {
+			s.needsRewrite = true
+		}
+	}
+}
+
+// productsFileInfo describes how a products file was written.
+type productsFileInfo struct {
+	// legacy is set for files written by older versions as a single JSON
+	// array
+	legacy bool
+	// superseded counts the records that a later record with the same ID
+	// replaced or deleted, and the deleting records themselves. Updates are
+	// appended as new records, so they pile up in normal operation.
+	superseded int
+	// redundant counts the records that repeat the previous record of their
+	// product unchanged
+	redundant int
+}
+
+// needsCompaction reports whether a file holding live products is worth
+// rewriting: it has redundant records, or more superseded records than live
+// ones.
+func (info productsFileInfo) needsCompaction(live int) bool {
+	return info.redundant > 0 || info.superseded > live
+}
+
+// readProductsFile reads the products file at path under a shared lock. An
+// empty file yields no products, and a file that can't be decoded is
+// reported as errCorruptProducts.
+func readProductsFile(path string) (map[string]models.Product, productsFileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, productsFileInfo{}, err
+	}
 	defer file.Close()
 
+	if err := lockFile(file, false); err != nil {
+		return nil, productsFileInfo{}, fmt.Errorf("failed to lock file: %w", err)
+	}
+	defer unlockFile(file)
+
 	fileInfo, err := file.Stat()
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to get file info")
-		return
+		return nil, productsFileInfo{}, fmt.Errorf("failed to get file info: %w", err)
 	}
 
 	if fileInfo.Size() == 0 {
-		return
+		return nil, productsFileInfo{}, nil
 	}
 
-	var products []models.Product
-	if err := json.NewDecoder(file).Decode(&products); err != nil {
-		logger.Error().Err(err).Msg("Failed to decode products.json file")
-		return
+	products, info, err := readProducts(file)
+	if err != nil {
+		return nil, productsFileInfo{}, fmt.Errorf("%w: %w", errCorruptProducts, err)
 	}
+	return products, info, nil
+}
 
-	for _, product := range products {
-		s.knownProductIDs[product.ID] = true
-		s.knownProducts[product.ID] = product
+// productRecord is one line of the products file. A deleted record removes
+// a product that was recorded earlier in the file.
+type productRecord struct {
+	models.Product
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// readProducts reads a products file with one JSON record per line, where
+// later records replace earlier ones with the same ID. Files written by older
+// versions as a single JSON array are accepted too and reported as legacy.
+// Either way only the last record of each product is kept, and the records
+// it replaced are counted as superseded.
+func readProducts(r io.Reader) (products map[string]models.Product, info productsFileInfo, err error) {
+	reader := bufio.NewReader(r)
+	products = make(map[string]models.Product)
+
+	for {
+		next, err := reader.Peek(1)
+		if err == io.EOF {
+			return products, info, nil
+		}
+		if err != nil {
+			return nil, info, err
+		}
+		if next[0] != ' ' && next[0] != '\t' && next[0] != '\r' && next[0] != '\n' {
+			break
+		}
+		reader.ReadByte()
 	}
-	logger.Info().Msgf("Loaded %d known products", len(s.knownProductIDs))
-	s.initialized = true
+
+	if next, _ := reader.Peek(1); next[0] == '[' {
+		var list []models.Product
+		if err := json.NewDecoder(reader).Decode(&list); err != nil {
+			return nil, info, err
+		}
+		for _, product := range list {
+			if previous, ok := products[product.ID]; ok {
+				info.superseded++
+				if reflect.DeepEqual(previous, product) {
+					info.redundant++
+				}
+			}
+			products[product.ID] = product
+		}
+		info.legacy = true
+		return products, info, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	var lineErr error
+	for line := 1; scanner.Scan(); line++ {
+		// Only the last line may be unreadable, which happens when the
+		// process died halfway through an append
+		if lineErr != nil {
+			return nil, info, lineErr
+		}
+
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record productRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			lineErr = fmt.Errorf("line %d: %w", line, err)
+			continue
+		}
+
+		previous, ok := products[record.ID]
+		switch {
+		case record.Deleted:
+			// Both the deleted record and the deletion are dead weight
+			info.superseded++
+			if ok {
+				info.superseded++
+			}
+			delete(products, record.ID)
+			continue
+		case !ok:
+		case reflect.DeepEqual(previous, record.Product):
+			info.superseded++
+			info.redundant++
+		default:
+			info.superseded++
+		}
+		products[record.ID] = record.Product
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, info, err
+	}
+	if lineErr != nil {
+		logger.Warning().Err(lineErr).Msg("Ignoring incomplete last record in products file")
+	}
+
+	return products, info, nil
 }
 
+// backupCorruptFile moves an undecodable products file aside so it can be
+// inspected later.
+func (s *UnifiStore) backupCorruptFile() {
+	backup := fmt.Sprintf("%s.corrupt-%s", s.cfg.ProductsFile, time.Now().Format("20060102-150405"))
+	if err := os.Rename(s.cfg.ProductsFile, backup); err != nil {
+		logger.Error().Err(err).Msg("Failed to back up corrupt products file")
+		return
+	}
+	logger.Warning().
+		Str("backup", backup).
+		Msg("Backed up corrupt products file")
+}
+
+// saveKnownProducts persists the pending changes by appending them to the
+// products file in a single write. Files in the legacy format are rewritten
+// instead.
 func (s *UnifiStore) saveKnownProducts() error {
-	logger.Info().Msg("Saving known products...")
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Create a slice with all products
-	allProducts := make([]models.Product, 0, len(s.knownProducts))
-	for _, product := range s.knownProducts {
-		allProducts = append(allProducts, product)
+	// Nothing is persisted, the pending changes only have to be dropped.
+	// Price points stay pending so the session's history can be served.
+	if s.cfg.Stateless {
+		s.pendingProducts = s.pendingProducts[:0]
+		s.needsRewrite = false
+		return nil
+	}
+
+	logger.Info().Msg("Saving known products...")
+
+	if err := s.savePriceHistory(); err != nil {
+		return err
+	}
+
+	if s.needsRewrite {
+		return s.rewriteProductsFile()
+	}
+
+	if len(s.pendingProducts) == 0 {
+		return nil
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	for _, record := range s.pendingProducts {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode products: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(s.cfg.ProductsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file, true); err != nil {
+		return fmt.Errorf("failed to lock file: %w", err)
+	}
+	defer unlockFile(file)
+
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		return fmt.Errorf("failed to append products: %w", err)
 	}
 
-	// Create the file with 0644 permissions
-	file, err := os.OpenFile(s.cfg.ProductsFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	saved := len(s.pendingProducts)
+	s.pendingProducts = s.pendingProducts[:0]
+
+	logger.Info().Msgf("Successfully saved %d product changes", saved)
+	return nil
+}
+
+// rewriteProductsFile replaces the products file with one record per known
+// product, dropping superseded and deleted records. The records are written
+// to a temporary file that is renamed over the products file, keeping the
+// previous file as a backup. Must be called with the mutex held.
+func (s *UnifiStore) rewriteProductsFile() error {
+	tempPath := s.cfg.ProductsFile + tempSuffix
+	file, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
+	defer os.Remove(tempPath)
 	defer file.Close()
 
+	ids := make([]string, 0, len(s.knownProducts))
+	for id := range s.knownProducts {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
 	// Use buffered writer for better performance
 	writer := bufio.NewWriter(file)
 	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "    ")
-
-	if err := encoder.Encode(allProducts); err != nil {
-		return fmt.Errorf("failed to encode products: %w", err)
+	for _, id := range ids {
+		if err := encoder.Encode(productRecord{Product: s.knownProducts[id]}); err != nil {
+			return fmt.Errorf("failed to encode products: %w", err)
+		}
 	}
 
 	if err := writer.Flush(); err != nil {
 		return fmt.Errorf("failed to flush writer: %w", err)
 	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	if err := replaceWithBackup(s.cfg.ProductsFile, tempPath); err != nil {
+		return err
+	}
 
-	// Clear pending products after successful save
 	s.pendingProducts = s.pendingProducts[:0]
+	s.needsRewrite = false
 
-	logger.Info().Msgf("Successfully saved %d products", len(allProducts))
+	logger.Info().Msgf("Successfully saved %d products", len(ids))
 	return nil
 }
 
-func (s *UnifiStore) fetchBuildID() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Compact rewrites the products file with a single record per known product.
+func (s *UnifiStore) Compact() error {
+	if s.cfg.Stateless {
+		return fmt.Errorf("there is no products file to compact in stateless mode")
+	}
+	if s.standby {
+		return fmt.Errorf("a standby can't compact the products file, run it with the primary's config")
+	}
+
+	s.loadOnce.Do(s.loadKnownProducts)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.rewriteProductsFile()
+}
+
+func (s *UnifiStore) fetchBuildID(log logger.Logger) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveFetch("build_id", start, err) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.HTTPTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.HomeURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	bypassCache(req, s.cfg.BypassCache)
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
+		return fmt.Errorf("%w: failed to execute request: %w", ErrNetwork, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return newStatusError(resp)
 	}
 
 	buffer := &bytes.Buffer{}
 	if _, err := io.Copy(buffer, resp.Body); err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return fmt.Errorf("%w: failed to read response body: %w", ErrNetwork, err)
 	}
 
-	matches := buildIDPattern.FindStringSubmatch(buffer.String())
-	if len(matches) < 2 {
-		return fmt.Errorf("failed to extract build ID from response")
+	buildID, strategy, ok := extractBuildID(buffer.String())
+	if !ok && isChallengePage(resp.Header, buffer.String()) {
+		s.httpClient.ReportBlocked(resp, ErrChallengePage)
+		return fmt.Errorf("%w instead of the home page", ErrChallengePage)
+	}
+	if !ok {
+		return fmt.Errorf("%w in response, tried %d patterns", ErrBuildIDNotFound, len(buildIDStrategies))
 	}
 
-	buildID := matches[1]
-	s.baseURL = fmt.Sprintf("https://store.ui.com/_next/data/%s/us/en.json", buildID)
-	logger.Info().Str("buildID", buildID).Msg("Successfully extracted build ID")
+	s.mutex.Lock()
+	s.buildID = buildID
+	s.buildIDFetchedAt = time.Now()
+	s.mutex.Unlock()
+	log.Info().Str("buildID", buildID).Str("strategy", strategy).Msg("Successfully extracted build ID")
 
 	return nil
 }
 
-func (s *UnifiStore) fetchProducts(category string) ([]models.Product, error) {
-	url := fmt.Sprintf("%s?category=%s&store=us&language=en", s.baseURL, category)
+func (s *UnifiStore) fetchProducts(t target, log logger.Logger) (products []models.Product, subCategories []models.SubCategory, err error) {
+	category := t.category
+	start := time.Now()
+	defer func() { metrics.ObserveFetch(category, start, err) }()
+
+	var body io.ReadCloser
+	if s.replayDir != "" {
+		body, err = openReplay(s.replayDir, t)
+	} else {
+		body, err = s.fetchListing(t)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	products, subCategories, err = parseProducts(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("category %s: %w", category, err)
+	}
+	if len(subCategories) == 0 {
+		log.Warning().Str("category", category).Msg("Category has no subcategories, the slug may have changed")
+	}
+	return products, subCategories, nil
+}
+
+// fetchListing requests the target's category listing from the store.
+func (s *UnifiStore) fetchListing(t target) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, t.dataURL(s.storeURL(), s.buildID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	bypassCache(req, s.cfg.BypassCache)
 
-	resp, err := s.httpClient.Do(req)
+	client := s.clientFor(t)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch products: %w", err)
+		return nil, fmt.Errorf("%w: failed to fetch products: %w", ErrNetwork, err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		resp.Body.Close()
+		return nil, newStatusError(resp)
 	}
 
+	body := bufio.NewReader(resp.Body)
+	if isHTML(resp.Header, body) {
+		resp.Body.Close()
+		client.ReportBlocked(resp, ErrChallengePage)
+		return nil, fmt.Errorf("category %s: %w instead of JSON", t.category, ErrChallengePage)
+	}
+
+	return peekedBody{body, resp.Body}, nil
+}
+
+// parseProducts decodes a category listing and flattens the products of all
+// its subcategories. The subcategories are returned as well.
+func parseProducts(r io.Reader) ([]models.Product, []models.SubCategory, error) {
 	var response models.Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to decode response: %w", decodeErrorKind(err), err)
 	}
 
 	var products []models.Product
 	for _, subCategory := range response.PageProps.SubCategories {
 		products = append(products, subCategory.Products...)
 	}
-	return products, nil
+	return products, response.PageProps.SubCategories, nil
+}
+
+// inPriceRange reports whether any variant of the product is priced within
+// the configured min_price/max_price range. A zero bound is treated as unset.
+// Only new products are filtered, changes to known products always alert.
+func (s *UnifiStore) inPriceRange(product models.Product) bool {
+	if s.cfg.MinPrice <= 0 && s.cfg.MaxPrice <= 0 {
+		return true
+	}
+
+	minCents := int(math.Round(s.cfg.MinPrice * 100))
+	maxCents := int(math.Round(s.cfg.MaxPrice * 100))
+
+	for _, variant := range product.Variants {
+		amount := variant.DisplayPrice.Amount
+		if s.cfg.MinPrice > 0 && amount < minCents {
+			continue
+		}
+		if s.cfg.MaxPrice > 0 && amount > maxCents {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// detectRemovals counts how many consecutive sweeps each known product has
+// been missing from and returns a removal alert for each product that
+// reaches the configured threshold.
+func (s *UnifiStore) detectRemovals(seen map[string]bool) (alerts []models.Event) {
+	if s.cfg.RemovalThreshold <= 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, product := range s.knownProducts {
+		if seen[id] {
+			delete(s.missingPasses, id)
+			continue
+		}
+
+		s.missingPasses[id]++
+		if s.missingPasses[id] != s.cfg.RemovalThreshold {
+			continue
+		}
+
+		s.log.Info().
+			Str("id", product.ID).
+			Str("title", product.Title).
+			Int("missingPasses", s.missingPasses[id]).
+			Msg("Product removed")
+
+		now := time.Now()
+		event := models.Event{Type: models.EventRemoved, Product: product, DetectedAt: now}
+		s.recordEvent(event)
+		if s.alertAllowed(s.log, event, now) {
+			alerts = append(alerts, event)
+		}
+
+		if s.cfg.DropRemoved {
+			delete(s.knownProductIDs, id)
+			delete(s.knownProducts, id)
+			delete(s.missingPasses, id)
+			delete(s.lastAlert, id)
+			// Queue a save so the removal is persisted
+			s.pendingProducts = append(s.pendingProducts, productRecord{Product: product, Deleted: true})
+		}
+	}
+
+	return alerts
+}
+
+// addCategory records that a known product is listed in category. Must be
+// called with the mutex held.
+func (s *UnifiStore) addCategory(id, category string) {
+	product, ok := s.knownProducts[id]
+	if !ok || slices.Contains(product.Categories, category) {
+		return
+	}
+
+	product.Categories = append(slices.Clone(product.Categories), category)
+	s.knownProducts[id] = product
+	s.pendingProducts = append(s.pendingProducts, productRecord{Product: product})
+}
+
+// markSeen updates when a known product was last listed. The time is only
+// persisted with the product's next saved change. Must be called with the
+// mutex held.
+func (s *UnifiStore) markSeen(id string, now time.Time) {
+	if product, ok := s.knownProducts[id]; ok {
+		product.LastSeen = now
+		s.knownProducts[id] = product
+	}
+}
+
+// claimNew marks a product as known and reports whether this call did so.
+// The check and the update happen together under the mutex, so when the
+// same product is processed for several categories at once exactly one of
+// them treats it as new and sends the alert. Must be called with the mutex
+// held.
+func (s *UnifiStore) claimNew(id string) bool {
+	if s.knownProductIDs[id] {
+		return false
+	}
+	s.knownProductIDs[id] = true
+	return true
+}
+
+// processProducts records the products fetched for a category and returns
+// the events to announce. They are sent by sendAlerts once the mutex is
+// released. Must be called with the mutex held.
+func (s *UnifiStore) processProducts(category string, products []models.Product, seen map[string]bool) (alerts []models.Event) {
+	now := time.Now()
+	for _, product := range products {
+		// Products listed in several categories are only compared and
+		// announced for the first one in a sweep
+		if seen[product.ID] {
+			s.addCategory(product.ID, category)
+			continue
+		}
+		seen[product.ID] = true
+
+		if s.claimNew(product.ID) {
+			product.Categories = []string{category}
+			product.FirstSeen = now
+			product.LastSeen = now
+			s.knownProducts[product.ID] = product
+			s.pendingProducts = append(s.pendingProducts, productRecord{Product: product})
+			s.pendingPrices = append(s.pendingPrices, pricePoints(models.Product{}, product, product.FirstSeen)...)
+
+			// Seeding the known set, don't alert
+			if !s.initialized {
+				continue
+			}
+
+			s.log.Info().
+				Str("id", product.ID).
+				Str("title", product.Title).
+				Msg("New product found")
+			s.stats.newFound++
+
+			if !s.inPriceRange(product) {
+				s.log.Info().
+					Str("id", product.ID).
+					Msg("Skipping notification, price outside configured range")
+				continue
+			}
+			if !s.titleFilter.Allows(product.Title) {
+				s.log.Info().
+					Str("id", product.ID).
+					Msg("Skipping notification, title filtered out")
+				continue
+			}
+
+			event := models.Event{Type: models.EventNew, Product: product, Category: category, DetectedAt: now}
+			if s.isUpcoming(product) {
+				s.log.Info().Str("id", product.ID).Msg("Product is upcoming")
+				event.Type = models.EventUpcoming
+			}
+			s.recordEvent(event)
+			// New products are always announced, the price range and title
+			// filter were checked above
+			s.startCooldown(product.ID, now)
+			alerts = append(alerts, event)
+		} else {
+			for _, event := range s.compareKnown(product) {
+				if s.initialized {
+					event.Category = category
+					event.DetectedAt = now
+					s.recordEvent(event)
+					if s.alertAllowed(s.log, event, now) {
+						alerts = append(alerts, event)
+					}
+				}
+			}
+			s.addCategory(product.ID, category)
+			s.markSeen(product.ID, now)
+		}
+	}
+
+	return alerts
+}
+
+// sendAlerts enriches, prepares and sends the alerts collected during a
+// sweep, or only a summary when there are more than max_alerts_per_sweep.
+// New products are announced in a single message when batch_alerts is
+// enabled. Fetching details and notifiers' retries may take a while, so it
+// must be called without the mutex held.
+func (s *UnifiStore) sendAlerts(alerts []models.Event) {
+	var batched []models.Event
+	for _, event := range s.capAlerts(alerts) {
+		newProduct := event.Type == models.EventNew || event.Type == models.EventUpcoming
+		if newProduct {
+			s.enrich(&event)
+		}
+		// Subcategories have no product image to check
+		if event.Type != models.EventNewSubCategory {
+			event = s.prepareEvent(s.cfg, event)
+		}
+		if s.cfg.BatchAlerts && newProduct {
+			batched = append(batched, event)
+			continue
+		}
+		notifier.Dispatch(s.log, s.notifiers, event)
+	}
+
+	if len(batched) > 0 {
+		notifier.DispatchBatch(s.log, s.notifiers, batched)
+	}
+}
+
+// RunOnce performs a single sweep of every category: new products are
+// announced, known products are diffed and the products file is saved once
+// enough changes are pending. An error is returned when the build ID or any
+// category could not be fetched.
+func (s *UnifiStore) RunOnce(ctx context.Context) error {
+	s.sweepMutex.Lock()
+	defer s.sweepMutex.Unlock()
+
+	return s.runOnce(ctx)
+}
+
+func (s *UnifiStore) runOnce(ctx context.Context) error {
+	s.loadOnce.Do(s.loadKnownProducts)
+
+	s.log = logger.With("sweep", logger.NewID())
+
+	// The build ID is fetched at most once per sweep
+	buildIDFresh := false
+	if s.buildIDExpired() {
+		if err := s.fetchBuildIDWithRetry(s.retryPolicy()); err != nil {
+			return fmt.Errorf("failed to fetch build ID: %w", err)
+		}
+		buildIDFresh = true
+	}
+
+	if s.cfg.AutoDiscoverCategories && s.replayDir == "" {
+		s.discoverOnce.Do(s.refreshCategories)
+	}
+
+	s.mutex.Lock()
+	s.stats.newFound = 0
+	s.mutex.Unlock()
+	s.refreshedClients = make(map[*customhttp.Client]bool)
+
+	seen := make(map[string]bool)
+	failed := 0
+	total := 0
+	var lastErr error
+	var alerts []models.Event
+
+	targets := s.targets()
+	for _, t := range targets {
+		if err := ctx.Err(); err != nil {
+			// The products are known now, so their alerts can't wait for
+			// the next sweep
+			s.sendAlerts(alerts)
+			return err
+		}
+
+		products, subCategories, err := s.fetchProductsWithRetry(t, s.retryPolicy())
+		if err != nil && !buildIDFresh && s.buildIDMayHaveExpired(err) {
+			s.log.Info().Err(err).Str("category", t.category).Msg("Build ID may have expired, refreshing it")
+			buildIDFresh = true
+			if err = s.fetchBuildIDWithRetry(s.retryPolicy()); err == nil {
+				products, subCategories, err = s.fetchProductsWithRetry(t, s.retryPolicy())
+			}
+		}
+		if err != nil {
+			s.log.Error().Err(err).Str("category", t.category).Str("storefront", t.storefront.Name).Msg("Failed to fetch products")
+			s.mutex.Lock()
+			s.recordCategoryError(t)
+			s.mutex.Unlock()
+			failed++
+			lastErr = err
+			continue
+		}
+
+		total += len(products)
+		t.tag(products)
+
+		s.mutex.Lock()
+		alerts = append(alerts, s.processProducts(t.category, products, seen)...)
+		alerts = append(alerts, s.checkSubCategories(t, subCategories)...)
+		s.mutex.Unlock()
+	}
+
+	sweepComplete := failed == 0
+
+	if !s.initialized && sweepComplete {
+		s.mutex.Lock()
+		s.initialized = true
+		seeded := len(s.knownProducts)
+		s.mutex.Unlock()
+
+		s.log.Info().Msgf("Seeded %d known products, alerts are now enabled", seeded)
+		if err := s.saveKnownProducts(); err != nil {
+			s.log.Error().Err(err).Msg("Failed to save known products")
+		}
+	}
+
+	// Only look for removals after a sweep where every category was
+	// fetched, otherwise a failed request would look like a delisting. An
+	// empty sweep is more likely broken parsing than the whole store being
+	// delisted.
+	if sweepComplete {
+		s.checkEmptySweep(total, len(targets))
+		if total > 0 {
+			alerts = append(alerts, s.detectRemovals(seen)...)
+			s.pruneStale()
+		}
+	}
+	s.sendAlerts(alerts)
+
+	// Check for pending products to save
+	s.mutex.Lock()
+	shouldSave := s.needsRewrite || (len(s.pendingProducts) > 0 && len(s.pendingProducts) >= s.cfg.SaveBatchSize)
+	s.mutex.Unlock()
+
+	if shouldSave {
+		if err := s.saveKnownProducts(); err != nil {
+			s.log.Error().Err(err).Msg("Failed to save known products")
+		}
+	}
+
+	if !sweepComplete {
+		// The cause only describes the sweep when every category failed
+		if failed < len(targets) {
+			return fmt.Errorf("failed to fetch %d of %d categories", failed, len(targets))
+		}
+		return fmt.Errorf("failed to fetch all %d categories: %w", failed, lastErr)
+	}
+
+	return nil
+}
+
+// FlushAlerts sends the alerts held back for the next digest or until quiet
+// hours end. They are only
+// kept in memory and the products they announce are already known, so it
+// must be called before exiting.
+func (s *UnifiStore) FlushAlerts() {
+	s.mutex.Lock()
+	notifiers := s.notifiers
+	s.mutex.Unlock()
+
+	notifier.Drain(notifiers)
+}
+
+// Flush saves the known products if any changes are pending.
+func (s *UnifiStore) Flush() error {
+	s.mutex.Lock()
+	// Only the primary writes the products file
+	if s.standby {
+		s.mutex.Unlock()
+		return nil
+	}
+	hasPending := len(s.pendingProducts) > 0 || s.needsRewrite || (len(s.pendingPrices) > 0 && !s.cfg.Stateless)
+	s.mutex.Unlock()
+
+	if !hasPending {
+		return nil
+	}
+	return s.saveKnownProducts()
 }
 
 func (s *UnifiStore) Start() {
 	logger.Info().Msg("Starting Monitor")
-	s.loadKnownProducts()
 
 	// Setup signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -223,92 +1139,76 @@ func (s *UnifiStore) Start() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create a ticker for periodic saves
-	saveTicker := time.NewTicker(5 * time.Minute)
-	defer saveTicker.Stop()
-
 	// Start signal handler
 	go func() {
 		<-sigChan
-		logger.Info().Msg("Received shutdown signal")
-		if err := s.saveKnownProducts(); err != nil {
-			logger.Error().Err(err).Msg("Failed to save products during shutdown")
-		}
-		cancel() // Cancel the context
-		logger.Info().Msg("Shutdown complete")
-		os.Exit(0)
+		logger.Info().Msg("Received shutdown signal, finishing the current sweep")
+		cancel()
+
+		<-sigChan
+		logger.Warning().Msg("Received second shutdown signal, exiting without saving")
+		os.Exit(1)
 	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			if err := s.fetchBuildID(); err != nil {
-				logger.Error().Err(err).Msg("Failed to fetch build ID")
-				time.Sleep(30 * time.Second)
-				continue
-			}
+	if s.standby && !s.runStandby(ctx) {
+		logger.Info().Msg("Shutdown complete")
+		os.Exit(0)
+	}
+	s.loadOnce.Do(s.loadKnownProducts)
 
-			for _, category := range s.categories {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					products, err := s.fetchProducts(category)
-					if err != nil {
-						logger.Error().Err(err).Msg("Failed to fetch products")
-						continue
-					}
+	// Products below save_batch_size are written at least every save_interval
+	saveTicker := time.NewTicker(s.cfg.SaveInterval)
+	defer saveTicker.Stop()
 
-					s.mutex.Lock()
-					for _, product := range products {
-						if !s.knownProductIDs[product.ID] {
-							s.knownProductIDs[product.ID] = true
-							s.knownProducts[product.ID] = product
-							s.pendingProducts = append(s.pendingProducts, product)
-							logger.Info().
-								Str("id", product.ID).
-								Str("title", product.Title).
-								Msg("New product found")
-
-							if err := s.discord.SendProduct(product); err != nil {
-								logger.Error().Err(err).Msg("Failed to send Discord notification")
-							}
-						}
-					}
-					s.mutex.Unlock()
-				}
-			}
+	if len(s.cfg.Watchlist) > 0 && s.replayDir != "" {
+		logger.Warning().Msg("Watchlist is not checked in replay mode")
+	} else if len(s.cfg.Watchlist) > 0 {
+		go s.watch(ctx, s.cfg.Watchlist, s.cfg.WatchInterval)
+	}
 
-			// Check for pending products to save
-			s.mutex.Lock()
-			shouldSave := len(s.pendingProducts) > 0 && (len(s.pendingProducts) >= s.cfg.SaveBatchSize)
-			s.mutex.Unlock()
+	for {
+		s.applyPendingReload()
 
-			if shouldSave {
-				if err := s.saveKnownProducts(); err != nil {
-					logger.Error().Err(err).Msg("Failed to save known products")
-				}
+		// Paused sweeps resume once the store answers a probe
+		if s.breakerOpen() && !s.probeStore(ctx) {
+			if !sleepWithJitter(ctx, s.cfg.BreakerCooldown, s.cfg.PollJitter) {
+				break
 			}
+			continue
+		}
 
-			// Check if it's time for a periodic save
-			select {
-			case <-saveTicker.C:
-				s.mutex.Lock()
-				hasPending := len(s.pendingProducts) > 0
-				s.mutex.Unlock()
+		started := time.Now()
+		err := s.RunOnce(ctx)
+		s.recordSweep(started)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Error().Err(err).Msg("Sweep failed")
+		}
+		s.recordBreaker(err)
 
-				if hasPending {
-					if err := s.saveKnownProducts(); err != nil {
-						logger.Error().Err(err).Msg("Failed to save known products")
-					}
-				}
-			default:
+		// Check if it's time for a periodic save
+		select {
+		case <-saveTicker.C:
+			if err := s.Flush(); err != nil {
+				logger.Error().Err(err).Msg("Failed to save known products")
 			}
+		default:
+		}
 
-			logger.Info().Msg("Sleeping for 30 seconds...")
-			time.Sleep(30 * time.Second)
+		delay, jitter := s.sweepDelay(err)
+		if !sleepWithJitter(ctx, delay, jitter) {
+			break
 		}
 	}
+
+	// Send the alerts and write the products still held in memory before
+	// exiting
+	s.FlushAlerts()
+	if err := s.Flush(); err != nil {
+		logger.Error().Err(err).Msg("Failed to save products during shutdown")
+	}
+	logger.Info().Msg("Shutdown complete")
+	os.Exit(0)
 }
@@ -0,0 +1,671 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/notifier"
+	"all-unifi-monitor/pkg/logger"
+)
+
+const testBuildID = "test-build"
+
+// mockStore serves the home page, category listings and product pages of a
+// fake store.
+type mockStore struct {
+	*httptest.Server
+
+	mutex sync.Mutex
+	// listings are the products listed per category
+	listings map[string][]models.Product
+	// pages are the product page data per storefront path and slug
+	pages map[string]any
+	// requests counts the requests per URL path
+	requests map[string]int
+}
+
+func newMockStore(t *testing.T) *mockStore {
+	t.Helper()
+
+	m := &mockStore{
+		listings: make(map[string][]models.Product),
+		pages:    make(map[string]any),
+		requests: make(map[string]int),
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockStore) serve(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.requests[r.URL.Path]++
+
+	if r.URL.Path == "/us/en" {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><script id="__NEXT_DATA__">{"buildId":"` + testBuildID + `"}</script></html>`))
+		return
+	}
+
+	prefix := "/_next/data/" + testBuildID + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix), ".json")
+
+	var data any
+	if storefront, slug, ok := strings.Cut(path, "/products/"); ok {
+		page, found := m.pages[storefront+"/"+slug]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		data = page
+	} else {
+		data = models.Response{PageProps: models.PageProps{SubCategories: []models.SubCategory{
+			{ID: "default", Products: m.listings[r.URL.Query().Get("category")]},
+		}}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// list replaces the products listed in category.
+func (m *mockStore) list(category string, products ...models.Product) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.listings[category] = products
+}
+
+// page serves data as the product page of slug on the storefront at path.
+func (m *mockStore) page(path, slug string, data any) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.pages[path+"/"+slug] = data
+}
+
+// requested returns how often the URL path was requested.
+func (m *mockStore) requested(path string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.requests[path]
+}
+
+// recorder is a notifier that keeps the events sent through it.
+type recorder struct {
+	mutex   sync.Mutex
+	events  []models.Event
+	batches [][]models.Event
+	alerts  []string
+	// onSend is called with every event before it is recorded
+	onSend func(models.Event)
+}
+
+func (r *recorder) Name() string {
+	return "recorder"
+}
+
+func (r *recorder) SendProduct(product models.Product) error {
+	return r.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (r *recorder) SendEvent(event models.Event) error {
+	if r.onSend != nil {
+		r.onSend(event)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recorder) SendEvents(events []models.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.batches = append(r.batches, events)
+	return nil
+}
+
+func (r *recorder) SendAlert(title, message string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.alerts = append(r.alerts, title)
+	return nil
+}
+
+// sent returns the events sent so far, one by one or in batches.
+func (r *recorder) sent() []models.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := append([]models.Event(nil), r.events...)
+	for _, batch := range r.batches {
+		events = append(events, batch...)
+	}
+	return events
+}
+
+// newTestStore returns a store for the mock store whose alerts go to the
+// returned recorder. settings are YAML config lines added to the defaults.
+func newTestStore(t *testing.T, m *mockStore, settings string) (*UnifiStore, *recorder) {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	yaml := "home_url: " + m.URL + "/us/en\n" +
+		"products_file: " + filepath.Join(dir, "products.json") + "\n" +
+		"categories: [all-wifi, all-switching]\n" +
+		"max_requests_per_second: 0\n" +
+		"max_retries: 0\n" +
+		settings
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		if s.instanceLock != nil {
+			s.instanceLock.Close()
+		}
+	})
+
+	s.SetHTTPClient(customhttp.NewClient())
+	r := &recorder{}
+	s.notifiers = []notifier.Notifier{r}
+	return s, r
+}
+
+// testProduct returns a product with a single variant at the given price in
+// cents.
+func testProduct(id string, cents int) models.Product {
+	return models.Product{
+		ID:    id,
+		Title: "Product " + id,
+		Slug:  "product-" + strings.ToLower(id),
+		Variants: []models.Variant{
+			{ID: id + "-v1", DisplayPrice: models.DisplayPrice{Amount: cents, Currency: "USD"}},
+		},
+	}
+}
+
+func TestSweepAgainstMockStore(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "save_batch_size: 1\n")
+
+	// Notifiers must be free to use the store while an alert is sent
+	var lockedDuringSend []string
+	r.onSend = func(event models.Event) {
+		if !s.mutex.TryLock() {
+			lockedDuringSend = append(lockedDuringSend, event.Product.ID)
+			return
+		}
+		s.mutex.Unlock()
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 200))
+	m.list("all-switching", testProduct("C", 300))
+
+	// The first sweep seeds the known products without alerting
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 0 {
+		t.Fatalf("seeding sweep sent %v, want no alerts", sent)
+	}
+	if got := len(s.KnownProducts()); got != 3 {
+		t.Fatalf("seeded %d products, want 3", got)
+	}
+
+	// A product listed afterwards is alerted exactly once
+	m.list("all-switching", testProduct("C", 300), testProduct("D", 400))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	sent := r.sent()
+	if len(sent) != 1 || sent[0].Type != models.EventNew || sent[0].Product.ID != "D" || sent[0].Category != "all-switching" {
+		t.Fatalf("sent %v, want a single new product alert for D in all-switching", sent)
+	}
+
+	// The next sweep sends no duplicate
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 1 {
+		t.Fatalf("repeated sweep sent %v, want no further alerts", sent[1:])
+	}
+	if len(lockedDuringSend) > 0 {
+		t.Errorf("alerts for %v were sent while the store mutex was held", lockedDuringSend)
+	}
+	// The build ID is reused until build_id_ttl passes
+	if got := m.requested("/us/en"); got != 1 {
+		t.Errorf("home page requested %d times, want 1", got)
+	}
+
+	// After a restart the saved products aren't announced again
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	s.instanceLock.Close()
+	restarted, err := New(s.cfg)
+	if err != nil {
+		t.Fatalf("failed to recreate store: %v", err)
+	}
+	defer restarted.instanceLock.Close()
+	restarted.SetHTTPClient(customhttp.NewClient())
+	after := &recorder{}
+	restarted.notifiers = []notifier.Notifier{after}
+
+	if err := restarted.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep after restart failed: %v", err)
+	}
+	if sent := after.sent(); len(sent) != 0 {
+		t.Errorf("sweep after restart sent %v, want no alerts", sent)
+	}
+}
+
+func TestParseProducts(t *testing.T) {
+	tests := []struct {
+		name              string
+		body              string
+		wantIDs           []string
+		wantSubCategories []string
+		wantErr           error
+	}{
+		{
+			name: "valid listing",
+			body: `{"pageProps":{"subCategories":[{"id":"access-points","title":"Access Points","products":[
+				{"id":"A","title":"U7 Pro","slug":"u7-pro","thumbnail":{"url":"https://example.com/a.png"},
+				 "variants":[{"id":"A-1","displayPrice":{"amount":18900,"currency":"USD"}}]}]}]}}`,
+			wantIDs:           []string{"A"},
+			wantSubCategories: []string{"access-points"},
+		},
+		{
+			name:              "listing missing its fields",
+			body:              `{"pageProps":{"subCategories":[{"products":[{"id":"A"}]}]}}`,
+			wantIDs:           []string{"A"},
+			wantSubCategories: []string{""},
+		},
+		{
+			name: "no page props",
+			body: `{}`,
+		},
+		{
+			name: "subcategories",
+			body: `{"pageProps":{"subCategories":[
+				{"id":"switches","products":[{"id":"A"},{"id":"B"}]},
+				{"title":"Accessories","products":[{"id":"C"}]},
+				{"id":"empty","products":[]}]}}`,
+			wantIDs:           []string{"A", "B", "C"},
+			wantSubCategories: []string{"switches", "Accessories", "empty"},
+		},
+		{
+			name:    "malformed JSON",
+			body:    `{"pageProps":{"subCategories":[{"products":[{"id":"A",]}]}}`,
+			wantErr: ErrSchemaChanged,
+		},
+		{
+			name:    "unexpected structure",
+			body:    `{"pageProps":{"subCategories":{"products":[]}}}`,
+			wantErr: ErrSchemaChanged,
+		},
+		{
+			name:    "truncated body",
+			body:    `{"pageProps":{"subCategories":[{"products":[{"id":"A"`,
+			wantErr: ErrNetwork,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			products, subCategories, err := parseProducts(strings.NewReader(test.body))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("parseProducts() error = %v, want %v", err, test.wantErr)
+			}
+
+			var ids []string
+			for _, product := range products {
+				ids = append(ids, product.ID)
+			}
+			if !slices.Equal(ids, test.wantIDs) {
+				t.Errorf("products = %v, want %v", ids, test.wantIDs)
+			}
+
+			var names []string
+			for _, subCategory := range subCategories {
+				names = append(names, subCategory.Name())
+			}
+			if !slices.Equal(names, test.wantSubCategories) {
+				t.Errorf("subcategories = %v, want %v", names, test.wantSubCategories)
+			}
+		})
+	}
+}
+
+func TestFetchProducts(t *testing.T) {
+	// A store listing of the all-wifi category, trimmed to three products
+	listing, err := os.ReadFile("testdata/listing-all-wifi.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		status            int
+		contentType       string
+		body              string
+		wantIDs           []string
+		wantSubCategories []string
+		wantErr           error
+	}{
+		{
+			name:        "store listing",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        string(listing),
+			wantIDs: []string{
+				"b8a6b4c3-6a3e-4c2b-9a44-2e6d0c5a7f01",
+				"4d2e8f1a-9c3b-4e5d-a6f7-8b9c0d1e2f3a",
+				"f0e1d2c3-b4a5-4968-8776-655443322110",
+			},
+			wantSubCategories: []string{"wifi-flagship", "wifi-outdoor"},
+		},
+		{
+			name:    "not found",
+			status:  http.StatusNotFound,
+			body:    "Not Found",
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "unavailable",
+			status:  http.StatusServiceUnavailable,
+			body:    "Service Unavailable",
+			wantErr: ErrStoreUnavailable,
+		},
+		{
+			name:        "malformed JSON",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"pageProps":{"subCategories":[{"products":[{"id":"A",]}]}}`,
+			wantErr:     ErrSchemaChanged,
+		},
+		{
+			name:        "challenge page",
+			status:      http.StatusOK,
+			contentType: "text/html",
+			body:        "<html><body>Just a moment...</body></html>",
+			wantErr:     ErrChallengePage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newMockStore(t)
+			s, _ := newTestStore(t, m, "stateless: true\n")
+			s.buildID = testBuildID
+
+			var query string
+			m.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.RawQuery
+				if test.contentType != "" {
+					w.Header().Set("Content-Type", test.contentType)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+
+			target := target{storefront: config.Storefront{Path: models.DefaultStorefrontPath}, category: "all-wifi"}
+			products, subCategories, err := s.fetchProducts(target, logger.Logger{})
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("fetchProducts() error = %v, want %v", err, test.wantErr)
+			}
+			if !strings.Contains(query, "category=all-wifi") {
+				t.Errorf("requested query %q, want the all-wifi category", query)
+			}
+
+			var ids []string
+			for _, product := range products {
+				ids = append(ids, product.ID)
+			}
+			if !slices.Equal(ids, test.wantIDs) {
+				t.Errorf("products = %v, want %v", ids, test.wantIDs)
+			}
+
+			var names []string
+			for _, subCategory := range subCategories {
+				names = append(names, subCategory.Name())
+			}
+			if !slices.Equal(names, test.wantSubCategories) {
+				t.Errorf("subcategories = %v, want %v", names, test.wantSubCategories)
+			}
+		})
+	}
+}
+
+func TestFetchProductsFixtureFields(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "stateless: true\n")
+	s.buildID = testBuildID
+	m.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/listing-all-wifi.json")
+	})
+
+	products, _, err := s.fetchProducts(target{storefront: config.Storefront{Path: models.DefaultStorefrontPath}, category: "all-wifi"}, logger.Logger{})
+	if err != nil {
+		t.Fatalf("fetchProducts() failed: %v", err)
+	}
+
+	product := products[0]
+	if product.Title != "U7 Pro" || product.Slug != "u7-pro" || product.Thumbnail.URL == "" || product.ShortDescription == "" {
+		t.Errorf("product = %+v, want the U7 Pro with its details", product)
+	}
+	if price := product.Variants[0].DisplayPrice; price.Amount != 18900 || price.Currency != "USD" {
+		t.Errorf("price = %+v, want 18900 USD", price)
+	}
+	if availability := products[2].Availability(); availability != models.AvailabilityComingSoon {
+		t.Errorf("availability of the U7 Outdoor = %q, want coming soon", availability)
+	}
+}
+
+func TestClaimNewOverlappingCategories(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "stateless: true\n")
+	s.log = logger.With("test", t.Name())
+	s.initialized = true
+
+	// Each category lists a window of products overlapping its neighbours
+	const categories, products, window = 16, 64, 24
+	listings := make([][]models.Product, categories)
+	for c := range listings {
+		for i := range window {
+			id := fmt.Sprintf("P%d", (c*products/categories+i)%products)
+			listings[c] = append(listings[c], testProduct(id, 100))
+		}
+	}
+
+	var wg sync.WaitGroup
+	alerts := make([][]models.Event, categories)
+	for c := range listings {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			// Categories processed side by side don't share what they've seen
+			seen := make(map[string]bool)
+			for _, product := range listings[c] {
+				s.mutex.Lock()
+				alerts[c] = append(alerts[c], s.processProducts(fmt.Sprintf("category-%d", c), []models.Product{product}, seen)...)
+				s.mutex.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	announced := make(map[string]int)
+	for _, events := range alerts {
+		for _, event := range events {
+			announced[event.Product.ID]++
+		}
+	}
+	if len(announced) != products {
+		t.Errorf("announced %d products, want %d", len(announced), products)
+	}
+	for id, count := range announced {
+		if count != 1 {
+			t.Errorf("product %s was announced %d times, want once", id, count)
+		}
+	}
+	if len(s.knownProductIDs) != products || len(s.knownProducts) != products {
+		t.Errorf("known %d IDs and %d products, want %d", len(s.knownProductIDs), len(s.knownProducts), products)
+	}
+}
+
+func TestReadProductsSupersededRecords(t *testing.T) {
+	record := func(id string, cents int, deleted bool) string {
+		line, _ := json.Marshal(productRecord{Product: testProduct(id, cents), Deleted: deleted})
+		return string(line) + "\n"
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		wantIDs        []string
+		wantSuperseded int
+		wantRedundant  int
+		wantCompact    bool
+	}{
+		{
+			name:    "one record per product",
+			body:    record("A", 100, false) + record("B", 200, false),
+			wantIDs: []string{"A", "B"},
+		},
+		{
+			name:           "update",
+			body:           record("A", 100, false) + record("B", 200, false) + record("A", 150, false),
+			wantIDs:        []string{"A", "B"},
+			wantSuperseded: 1,
+		},
+		{
+			name:           "deletion",
+			body:           record("A", 100, false) + record("B", 200, false) + record("A", 100, true),
+			wantIDs:        []string{"B"},
+			wantSuperseded: 2,
+			wantCompact:    true,
+		},
+		{
+			name:           "unchanged repeat",
+			body:           record("A", 100, false) + record("B", 200, false) + record("A", 100, false),
+			wantIDs:        []string{"A", "B"},
+			wantSuperseded: 1,
+			wantRedundant:  1,
+			wantCompact:    true,
+		},
+		{
+			name:           "more updates than products",
+			body:           record("A", 100, false) + record("A", 110, false) + record("A", 120, false),
+			wantIDs:        []string{"A"},
+			wantSuperseded: 2,
+			wantCompact:    true,
+		},
+		{
+			name:           "legacy array",
+			body:           `[{"id":"A","title":"A"},{"id":"B"},{"id":"A","title":"A"},{"id":"B","title":"B"}]`,
+			wantIDs:        []string{"A", "B"},
+			wantSuperseded: 2,
+			wantRedundant:  1,
+			wantCompact:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			products, info, err := readProducts(strings.NewReader(test.body))
+			if err != nil {
+				t.Fatalf("readProducts() error = %v", err)
+			}
+
+			var ids []string
+			for id := range products {
+				ids = append(ids, id)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, test.wantIDs) {
+				t.Errorf("products = %v, want %v", ids, test.wantIDs)
+			}
+			if info.superseded != test.wantSuperseded || info.redundant != test.wantRedundant {
+				t.Errorf("superseded = %d, redundant = %d, want %d and %d", info.superseded, info.redundant, test.wantSuperseded, test.wantRedundant)
+			}
+			if got := info.needsCompaction(len(products)); got != test.wantCompact {
+				t.Errorf("needsCompaction() = %v, want %v", got, test.wantCompact)
+			}
+		})
+	}
+}
+
+func TestCompactOnLoadKeepsUpdatedFile(t *testing.T) {
+	m := newMockStore(t)
+	s, _ := newTestStore(t, m, "compact_on_load: true\n")
+
+	writeRecords(t, s.cfg.ProductsFile, "", testProduct("A", 100), testProduct("B", 200), testProduct("A", 150))
+	s.loadOnce.Do(s.loadKnownProducts)
+	if s.needsRewrite {
+		t.Error("a products file with a normal update is rewritten on load")
+	}
+}
+
+func TestFlushAlertsSendsDigest(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "stateless: true\n")
+	s.notifiers = []notifier.Notifier{notifier.NewDigest([]notifier.Notifier{r}, time.Hour)}
+
+	m.list("all-wifi", testProduct("A", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+
+	m.list("all-wifi", testProduct("A", 100), testProduct("B", 200))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if sent := r.sent(); len(sent) != 0 {
+		t.Fatalf("sent %v before the digest is due", sent)
+	}
+
+	// The monitor shuts down before the digest interval ends
+	s.FlushAlerts()
+	sent := r.sent()
+	if len(sent) != 1 || sent[0].Product.ID != "B" {
+		t.Errorf("sent %v on shutdown, want the new product alert for B", sent)
+	}
+}
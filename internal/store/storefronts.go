@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+)
+
+// target is a category of a storefront to sweep. The main store has an
+// empty storefront name.
+type target struct {
+	storefront config.Storefront
+	category   string
+}
+
+// targets lists every category to sweep: the configured categories of the
+// main store followed by those of each additional storefront.
+func (s *UnifiStore) targets() []target {
+	main := config.Storefront{Path: models.DefaultStorefrontPath}
+
+	targets := make([]target, 0, len(s.categories))
+	for _, category := range s.categories {
+		targets = append(targets, target{storefront: main, category: category})
+	}
+	for _, storefront := range s.cfg.Storefronts {
+		for _, category := range storefront.Categories {
+			targets = append(targets, target{storefront: storefront, category: category})
+		}
+	}
+	return targets
+}
+
+// dataURL returns the Next.js data URL listing the target's category on the
+// store at base.
+func (t target) dataURL(base, buildID string) string {
+	path := strings.Trim(t.storefront.Path, "/")
+	query := url.Values{"category": {t.category}}
+	// Paths start with the store region and language, e.g. us/en
+	if parts := strings.Split(path, "/"); len(parts) >= 2 {
+		query.Set("store", parts[0])
+		query.Set("language", parts[1])
+	}
+	return fmt.Sprintf("%s/_next/data/%s/%s.json?%s", base, buildID, path, query.Encode())
+}
+
+// storeURL returns the scheme and host of home_url, which the store's data
+// URLs are requested from.
+func (s *UnifiStore) storeURL() string {
+	home, err := url.Parse(s.cfg.HomeURL)
+	if err != nil || home.Host == "" {
+		return models.StoreURL
+	}
+	return home.Scheme + "://" + home.Host
+}
+
+// tag records the storefront products were found in. Products of the main
+// store are left untagged.
+func (t target) tag(products []models.Product) {
+	if t.storefront.Name == "" {
+		return
+	}
+	for i := range products {
+		products[i].Storefront = t.storefront.Name
+		products[i].StorefrontPath = t.storefrontPath()
+	}
+}
+
+// storefrontPath is the path recorded on the target's products, empty for
+// the main store.
+func (t target) storefrontPath() string {
+	if t.storefront.Name == "" {
+		return ""
+	}
+	return strings.Trim(t.storefront.Path, "/")
+}
+
+// key identifies the target across sweeps.
+func (t target) key() string {
+	return t.storefront.Name + "/" + t.category
+}
@@ -0,0 +1,40 @@
+package store
+
+// streamBuffer is how many events a live feed subscriber may fall behind
+// before further events are dropped for it.
+const streamBuffer = 64
+
+// Subscribe registers a live feed of detection events. Every event recorded
+// from now on is sent to the returned channel, except while the subscriber
+// is streamBuffer events behind, so a slow client can't stall a sweep. The
+// returned function unregisters the subscriber and closes the channel; it
+// must be called once the feed is no longer read.
+func (s *UnifiStore) Subscribe() (<-chan HistoryEntry, func()) {
+	events := make(chan HistoryEntry, streamBuffer)
+
+	s.mutex.Lock()
+	s.subscribers[events] = struct{}{}
+	s.mutex.Unlock()
+
+	unsubscribe := func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if _, ok := s.subscribers[events]; ok {
+			delete(s.subscribers, events)
+			close(events)
+		}
+	}
+	return events, unsubscribe
+}
+
+// publish sends the entry to every live feed subscriber that has room for
+// it. Must be called with the mutex held.
+func (s *UnifiStore) publish(entry HistoryEntry) {
+	for events := range s.subscribers {
+		select {
+		case events <- entry:
+		default:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"time"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// checkSubCategories returns an alert for each subcategory that wasn't
+// listed for the target before. The first listing of a target, like the first sweep, only
+// records what's there. Subcategories are remembered until restart, so one
+// that disappears and comes back isn't announced again. Must be called with
+// the mutex held.
+func (s *UnifiStore) checkSubCategories(t target, subCategories []models.SubCategory) (alerts []models.Event) {
+	known, listed := s.subCategories[t.key()]
+	if !listed {
+		known = make(map[string]bool, len(subCategories))
+		s.subCategories[t.key()] = known
+	}
+
+	for _, subCategory := range subCategories {
+		name := subCategory.Name()
+		if name == "" || known[name] {
+			continue
+		}
+		known[name] = true
+
+		if !listed || !s.initialized {
+			continue
+		}
+
+		title := subCategory.Title
+		if title == "" {
+			title = name
+		}
+		s.log.Info().
+			Str("category", t.category).
+			Str("subCategory", title).
+			Msg("New subcategory found")
+
+		event := models.Event{
+			Type:        models.EventNewSubCategory,
+			Category:    t.category,
+			SubCategory: title,
+			DetectedAt:  time.Now(),
+			Product: models.Product{
+				Storefront:     t.storefront.Name,
+				StorefrontPath: t.storefrontPath(),
+			},
+		}
+		s.recordEvent(event)
+		alerts = append(alerts, event)
+	}
+
+	return alerts
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// thumbnailCheckTTL is how long the result of checking a thumbnail URL is
+// reused.
+const thumbnailCheckTTL = time.Hour
+
+type thumbnailCheck struct {
+	ok        bool
+	checkedAt time.Time
+}
+
+// thumbnails checks that product images load before they are put in alerts,
+// so embeds don't render with a broken image.
+type thumbnails struct {
+	client *customhttp.Client
+	mutex  sync.Mutex
+	checks map[string]thumbnailCheck
+}
+
+func newThumbnails(timeout time.Duration) *thumbnails {
+	client := customhttp.NewClient()
+	client.SetTimeout(timeout)
+	return &thumbnails{client: client, checks: make(map[string]thumbnailCheck)}
+}
+
+// resolve returns the image URL to use for a product thumbnail according to
+// verify_thumbnails, fallback_thumbnail_url and image_proxy_prefix.
+func (t *thumbnails) resolve(cfg *config.Config, url string) string {
+	if cfg.VerifyThumbnails && (url == "" || !t.reachable(url, cfg.HTTPTimeout)) {
+		if cfg.FallbackThumbnailURL == "" {
+			return config.DefaultThumbnailURL
+		}
+		return cfg.FallbackThumbnailURL
+	}
+	if url == "" {
+		return url
+	}
+	return cfg.ImageProxyPrefix + url
+}
+
+// reachable sends a HEAD request for url, reusing recent results.
+func (t *thumbnails) reachable(url string, timeout time.Duration) bool {
+	t.mutex.Lock()
+	check, ok := t.checks[url]
+	t.mutex.Unlock()
+	if ok && time.Since(check.checkedAt) < thumbnailCheckTTL {
+		return check.ok
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	check = thumbnailCheck{checkedAt: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err == nil {
+		req.Header.Set("Accept", "image/*")
+		if resp, err := t.client.Do(req); err == nil {
+			resp.Body.Close()
+			check.ok = resp.StatusCode >= 200 && resp.StatusCode < 300
+		}
+	}
+
+	t.mutex.Lock()
+	t.checks[url] = check
+	t.prune(check.checkedAt)
+	t.mutex.Unlock()
+
+	if !check.ok {
+		logger.Warning().Str("url", url).Msg("Thumbnail is unreachable, using the fallback image")
+	}
+	return check.ok
+}
+
+// prune drops the checks that are too old to be reused, so images of
+// products long gone don't pile up. Must be called with the mutex held.
+func (t *thumbnails) prune(now time.Time) {
+	for url, check := range t.checks {
+		if now.Sub(check.checkedAt) >= thumbnailCheckTTL {
+			delete(t.checks, url)
+		}
+	}
+}
+
+// prepareEvent fixes up an event's thumbnail and puts the primary variant
+// first before it is sent. Checking the thumbnail may send a request, so it
+// must be called without the store mutex held.
+func (s *UnifiStore) prepareEvent(cfg *config.Config, event models.Event) models.Event {
+	event.Product.Thumbnail.URL = s.thumbnails.resolve(cfg, event.Product.Thumbnail.URL)
+	event.Product.Variants = primaryFirst(cfg.PrimaryVariant, event.Product.Variants)
+	event.Variants = primaryFirst(cfg.PrimaryVariant, event.Variants)
+	return event
+}
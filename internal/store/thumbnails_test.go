@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"all-unifi-monitor/internal/config"
+)
+
+func TestThumbnailsResolve(t *testing.T) {
+	var heads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		heads.Add(1)
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{VerifyThumbnails: true, HTTPTimeout: time.Second, FallbackThumbnailURL: "https://example.com/fallback.png"}
+	thumbnails := newThumbnails(time.Second)
+
+	image := server.URL + "/image.png"
+	for range 2 {
+		if got := thumbnails.resolve(cfg, image); got != image {
+			t.Errorf("resolve(%q) = %q, want it unchanged", image, got)
+		}
+	}
+	if got := heads.Load(); got != 1 {
+		t.Errorf("sent %d HEAD requests for the same image, want 1", got)
+	}
+
+	if got := thumbnails.resolve(cfg, server.URL+"/missing.png"); got != cfg.FallbackThumbnailURL {
+		t.Errorf("resolve() of a missing image = %q, want the fallback", got)
+	}
+	if got := thumbnails.resolve(cfg, ""); got != cfg.FallbackThumbnailURL {
+		t.Errorf("resolve() without image = %q, want the fallback", got)
+	}
+}
+
+func TestThumbnailsPruneExpiredChecks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	thumbnails := newThumbnails(time.Second)
+	expired := time.Now().Add(-thumbnailCheckTTL - time.Minute)
+	thumbnails.checks["https://example.com/old.png"] = thumbnailCheck{ok: true, checkedAt: expired}
+	thumbnails.checks["https://example.com/recent.png"] = thumbnailCheck{ok: true, checkedAt: time.Now()}
+
+	thumbnails.reachable(server.URL+"/new.png", time.Second)
+
+	if _, ok := thumbnails.checks["https://example.com/old.png"]; ok {
+		t.Error("expired check was kept")
+	}
+	for _, url := range []string{"https://example.com/recent.png", server.URL + "/new.png"} {
+		if _, ok := thumbnails.checks[url]; !ok {
+			t.Errorf("check of %s was dropped", url)
+		}
+	}
+}
+
+func TestThumbnailsCheckedWithoutStoreMutex(t *testing.T) {
+	m := newMockStore(t)
+	s, r := newTestStore(t, m, "stateless: true\nverify_thumbnails: true\n")
+
+	var locked atomic.Bool
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !s.mutex.TryLock() {
+			locked.Store(true)
+			return
+		}
+		s.mutex.Unlock()
+	}))
+	defer images.Close()
+
+	m.list("all-wifi", testProduct("A", 100))
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("seeding sweep failed: %v", err)
+	}
+
+	product := testProduct("B", 200)
+	product.Thumbnail.URL = images.URL + "/b.png"
+	m.list("all-wifi", testProduct("A", 100), product)
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+
+	if sent := r.sent(); len(sent) != 1 || sent[0].Product.Thumbnail.URL != product.Thumbnail.URL {
+		t.Fatalf("sent %v, want one alert with the checked thumbnail", sent)
+	}
+	if locked.Load() {
+		t.Error("thumbnail was checked while the store mutex was held")
+	}
+}
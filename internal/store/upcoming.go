@@ -0,0 +1,34 @@
+package store
+
+import (
+	"strings"
+
+	"all-unifi-monitor/internal/models"
+)
+
+// isUpcoming reports whether the product looks like a placeholder listed
+// ahead of its launch: every variant is priced at zero, when
+// upcoming_zero_price is enabled, or the title contains one of
+// upcoming_title_markers.
+func (s *UnifiStore) isUpcoming(product models.Product) bool {
+	if s.cfg.UpcomingZeroPrice && len(product.Variants) > 0 && !hasPrice(product) {
+		return true
+	}
+
+	title := strings.ToLower(product.Title)
+	for _, marker := range s.cfg.UpcomingTitleMarkers {
+		if marker != "" && strings.Contains(title, strings.ToLower(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrice(product models.Product) bool {
+	for _, variant := range product.Variants {
+		if variant.DisplayPrice.Amount > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"all-unifi-monitor/internal/config"
+	"all-unifi-monitor/internal/models"
+)
+
+// primaryFirst returns a copy of variants with the one primary_variant
+// selects moved to the front, since alerts showing a single variant show the
+// first. The others keep the store's order. Variants without a price yet are
+// only selected when none has one.
+func primaryFirst(mode string, variants []models.Variant) []models.Variant {
+	if mode == config.PrimaryVariantFirst || len(variants) < 2 {
+		return variants
+	}
+
+	primary := -1
+	for i, variant := range variants {
+		amount := variant.DisplayPrice.Amount
+		if amount == 0 {
+			continue
+		}
+		if primary < 0 {
+			primary = i
+			continue
+		}
+
+		best := variants[primary].DisplayPrice.Amount
+		if mode == config.PrimaryVariantCheapest && amount < best ||
+			mode == config.PrimaryVariantMostExpensive && amount > best {
+			primary = i
+		}
+	}
+	if primary <= 0 {
+		return variants
+	}
+
+	ordered := make([]models.Variant, 0, len(variants))
+	ordered = append(ordered, variants[primary])
+	ordered = append(ordered, variants[:primary]...)
+	return append(ordered, variants[primary+1:]...)
+}
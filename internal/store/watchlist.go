@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/internal/notifier"
+	"all-unifi-monitor/pkg/logger"
+)
+
+type productResponse struct {
+	PageProps struct {
+		Product *models.Product `json:"product"`
+	} `json:"pageProps"`
+}
+
+// errProductNotFound is returned by fetchProductPage when the store has no
+// page for the requested slug.
+var errProductNotFound = errors.New("product not found")
+
+// fetchProduct fetches the product with the given slug on the storefront at
+// path, or the main storefront when path is empty.
+func (s *UnifiStore) fetchProduct(path, slug string) (models.Product, error) {
+	var response productResponse
+	if err := s.fetchProductPage(path, slug, &response); err != nil {
+		return models.Product{}, err
+	}
+
+	if response.PageProps.Product == nil {
+		return models.Product{}, errProductNotFound
+	}
+
+	product := *response.PageProps.Product
+	product.StorefrontPath = path
+	return product, nil
+}
+
+// watchSlug resolves a watchlist entry to the storefront path and slug of a
+// product. Entries matching a known product ID are translated, anything else
+// is assumed to be a slug on the main storefront.
+func (s *UnifiStore) watchSlug(entry string) (path, slug string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if product, ok := s.knownProducts[entry]; ok {
+		return product.StorefrontPath, product.Slug
+	}
+	return "", entry
+}
+
+// watch polls every watchlist entry each interval and alerts when a
+// watched product's price changes, its page appears or disappears, or its
+// variants sell out or come back in stock.
+func (s *UnifiStore) watch(ctx context.Context, watchlist []string, interval time.Duration) {
+	// Last observed state per entry; nil means the product was unavailable
+	lastSeen := make(map[string]*models.Product)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Watched products aren't polled while sweeps are paused either
+		s.mutex.Lock()
+		ready := s.buildID != "" && s.breaker.state != breakerOpen && s.breaker.state != breakerHalfOpen
+		s.mutex.Unlock()
+		if !ready {
+			continue
+		}
+
+		for _, entry := range watchlist {
+			product, err := s.fetchProduct(s.watchSlug(entry))
+			// Changes found in one poll share their detection time, so the
+			// alert_cooldown lets all of them through
+			now := time.Now()
+			if err != nil && !errors.Is(err, errProductNotFound) {
+				logger.Error().Err(err).Str("entry", entry).Msg("Failed to fetch watched product")
+				continue
+			}
+
+			previous, polled := lastSeen[entry]
+			if errors.Is(err, errProductNotFound) {
+				lastSeen[entry] = nil
+				if polled && previous != nil {
+					s.sendWatchEvent(models.Event{Type: models.EventRemoved, Product: *previous}, now)
+				}
+				continue
+			}
+
+			lastSeen[entry] = &product
+			if !polled {
+				continue
+			}
+
+			if previous == nil {
+				s.sendWatchEvent(models.Event{Type: models.EventBackInStock, Product: product, Variants: product.Variants}, now)
+				continue
+			}
+
+			if event, ok := priceChanges(*previous, product); ok {
+				s.sendWatchEvent(event, now)
+			}
+			for _, event := range stockChanges(*previous, product) {
+				s.sendWatchEvent(event, now)
+			}
+		}
+	}
+}
+
+// stockChanges compares the availability of a watched product's variants
+// between two polls. Variants that are in stock after being sold out or
+// coming soon, or that weren't listed before, are back in stock. Variants
+// that were in stock and are now sold out are sold out.
+func stockChanges(previous, product models.Product) []models.Event {
+	before := make(map[string]models.Availability, len(previous.Variants))
+	for _, variant := range previous.Variants {
+		before[variant.ID] = variant.Availability()
+	}
+
+	var restocked, soldOut []models.Variant
+	for _, variant := range product.Variants {
+		was, listed := before[variant.ID]
+		now := variant.Availability()
+		switch {
+		case !listed && now != models.AvailabilitySoldOut:
+			restocked = append(restocked, variant)
+		case now == models.AvailabilityInStock && (was == models.AvailabilitySoldOut || was == models.AvailabilityComingSoon):
+			restocked = append(restocked, variant)
+		case now == models.AvailabilitySoldOut && was == models.AvailabilityInStock:
+			soldOut = append(soldOut, variant)
+		}
+	}
+
+	var events []models.Event
+	if len(restocked) > 0 {
+		events = append(events, models.Event{Type: models.EventBackInStock, Product: product, Variants: restocked})
+	}
+	if len(soldOut) > 0 {
+		events = append(events, models.Event{Type: models.EventSoldOut, Product: product, Variants: soldOut})
+	}
+	return events
+}
+
+func (s *UnifiStore) sendWatchEvent(event models.Event, now time.Time) {
+	event.DetectedAt = now
+	logger.Info().
+		Str("id", event.Product.ID).
+		Str("title", event.Product.Title).
+		Str("event", string(event.Type)).
+		Msg("Watched product changed")
+
+	s.mutex.Lock()
+	s.recordEvent(event)
+	allowed := s.alertAllowed(logger.Logger{}, event, event.DetectedAt)
+	notifiers, cfg := s.notifiers, s.cfg
+	s.mutex.Unlock()
+
+	if !allowed {
+		return
+	}
+	notifier.Dispatch(logger.Logger{}, notifiers, s.prepareEvent(cfg, event))
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"all-unifi-monitor/internal/models"
+)
+
+func TestStockChanges(t *testing.T) {
+	variants := func(statuses ...string) models.Product {
+		product := models.Product{ID: "A", Title: "Product A"}
+		for i, status := range statuses {
+			product.Variants = append(product.Variants, models.Variant{ID: string(rune('a' + i)), Status: status})
+		}
+		return product
+	}
+
+	tests := []struct {
+		name     string
+		previous models.Product
+		product  models.Product
+		want     map[models.EventType][]string
+	}{
+		{"unchanged", variants("Available", "SoldOut"), variants("Available", "SoldOut"), map[models.EventType][]string{}},
+		{"restocked", variants("SoldOut", "Available"), variants("Available", "Available"), map[models.EventType][]string{models.EventBackInStock: {"a"}}},
+		{"released", variants("ComingSoon"), variants("Available"), map[models.EventType][]string{models.EventBackInStock: {"a"}}},
+		{"sold out", variants("Available", "Available"), variants("Available", "SoldOut"), map[models.EventType][]string{models.EventSoldOut: {"b"}}},
+		{"new variant", variants("Available"), variants("Available", "Available"), map[models.EventType][]string{models.EventBackInStock: {"b"}}},
+		{"new sold out variant", variants("Available"), variants("Available", "SoldOut"), map[models.EventType][]string{}},
+		{"both", variants("SoldOut", "Available"), variants("Available", "SoldOut"), map[models.EventType][]string{models.EventBackInStock: {"a"}, models.EventSoldOut: {"b"}}},
+		{"unknown status", variants("Available"), variants("Discontinued"), map[models.EventType][]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[models.EventType][]string{}
+			for _, event := range stockChanges(tt.previous, tt.product) {
+				for _, variant := range event.Variants {
+					got[event.Type] = append(got[event.Type], variant.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stockChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+const (
+	maxRetries = 3
+	retryDelay = 5 * time.Second
+
+	// Teams rejects payloads over 28KB, keep descriptions well below that
+	maxDescriptionLength = 2000
+)
+
+type message struct {
+	Type        string       `json:"type"`
+	Attachments []attachment `json:"attachments"`
+}
+
+type attachment struct {
+	ContentType string `json:"contentType"`
+	Content     card   `json:"content"`
+}
+
+type card struct {
+	Schema  string    `json:"$schema"`
+	Type    string    `json:"type"`
+	Version string    `json:"version"`
+	Body    []element `json:"body"`
+	Actions []action  `json:"actions,omitempty"`
+}
+
+type element struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Size   string `json:"size,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Wrap   bool   `json:"wrap,omitempty"`
+	Facts  []fact `json:"facts,omitempty"`
+}
+
+type fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type action struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type Webhook struct {
+	url        string
+	httpClient *customhttp.Client
+	dryRun     bool
+	retry      customhttp.RetryPolicy
+}
+
+func New(url string) *Webhook {
+	return &Webhook{
+		url:        url,
+		httpClient: customhttp.NewClient(),
+	}
+}
+
+func (w *Webhook) Name() string {
+	return "teams"
+}
+
+// SetDryRun makes the webhook log rendered cards instead of posting them.
+func (w *Webhook) SetDryRun(enabled bool) {
+	w.dryRun = enabled
+}
+
+// SetTimeout changes how long a webhook request may take.
+func (w *Webhook) SetTimeout(timeout time.Duration) {
+	w.httpClient.SetTimeout(timeout)
+}
+
+// SetRetryPolicy retries cards that failed with a network error or a 5xx
+// response.
+func (w *Webhook) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	w.retry = policy
+}
+
+func (w *Webhook) SendProduct(product models.Product) error {
+	return w.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (w *Webhook) SendEvent(event models.Event) error {
+	payload, err := json.Marshal(buildMessage(event))
+	if err != nil {
+		return fmt.Errorf("failed to marshal teams payload: %w", err)
+	}
+
+	if w.dryRun {
+		logger.Info().RawJSON("payload", payload).Msg("Dry run, skipping Teams webhook")
+		return nil
+	}
+
+	return w.retry.Do(w.Name(), func() error {
+		for attempt := 0; ; attempt++ {
+			limited, err := w.send(payload)
+			if err != nil || !limited {
+				return err
+			}
+			if attempt >= maxRetries {
+				return fmt.Errorf("teams rate limit persisted after %d retries", maxRetries)
+			}
+
+			// Rate limited, wait and retry
+			time.Sleep(retryDelay)
+		}
+	})
+}
+
+func buildMessage(event models.Event) message {
+	product := event.Product
+
+	heading := "🎉 New Product Alert!"
+	switch event.Type {
+	case models.EventUpcoming:
+		heading = "🔜 Upcoming Product"
+	case models.EventAvailable:
+		heading = "✅ Now Available"
+	case models.EventRemoved:
+		heading = "🚫 Product Removed"
+	case models.EventBackInStock:
+		heading = "🔁 New Variant / Back in Stock"
+	case models.EventPriceChange:
+		heading = "💲 Price Change"
+	case models.EventSale:
+		heading = "🏷️ Sale"
+	case models.EventUpdated:
+		heading = "✏️ Product Updated"
+	case models.EventInStock:
+		heading = "🚀 Now In Stock"
+	case models.EventSoldOut:
+		heading = "⛔ Sold Out"
+	case models.EventNewSubCategory:
+		heading = "🗂️ New Subcategory in " + event.Category
+	}
+
+	body := []element{
+		{Type: "TextBlock", Text: heading, Weight: "Bolder"},
+		{Type: "TextBlock", Text: event.Title(), Size: "Large", Weight: "Bolder", Wrap: true},
+	}
+	if product.Thumbnail.URL != "" {
+		body = append(body, element{Type: "Image", URL: product.Thumbnail.URL, Size: "Medium"})
+	}
+	if product.ShortDescription != "" {
+		body = append(body, element{Type: "TextBlock", Text: truncate(product.ShortDescription, maxDescriptionLength), Wrap: true})
+	}
+	if len(product.Variants) > 0 {
+		body = append(body, element{
+			Type:  "FactSet",
+			Facts: []fact{{Title: "Price", Value: product.Variants[0].Price()}},
+		})
+	}
+
+	return message{
+		Type: "message",
+		Attachments: []attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: card{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+				Actions: []action{{
+					Type:  "Action.OpenUrl",
+					Title: "Open in Store",
+					URL:   event.URL(),
+				}},
+			},
+		}},
+	}
+}
+
+func truncate(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// send posts the card once and reports whether Teams rate limited the
+// request and it should be retried.
+func (w *Webhook) send(payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to create teams request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to send teams webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true, nil
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return false, &customhttp.StatusError{Service: "teams webhook", StatusCode: resp.StatusCode}
+	}
+
+	return false, nil
+}
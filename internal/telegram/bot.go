@@ -0,0 +1,206 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	customhttp "all-unifi-monitor/internal/http"
+	"all-unifi-monitor/internal/message"
+	"all-unifi-monitor/internal/models"
+	"all-unifi-monitor/pkg/logger"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+const maxRetries = 3
+
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+type Bot struct {
+	token      string
+	chatID     string
+	httpClient *customhttp.Client
+	dryRun     bool
+	retry      customhttp.RetryPolicy
+	template   *message.Template
+}
+
+func New(botToken, chatID string) *Bot {
+	return &Bot{
+		token:      botToken,
+		chatID:     chatID,
+		httpClient: customhttp.NewClient(),
+	}
+}
+
+func (b *Bot) Name() string {
+	return "telegram"
+}
+
+// SetMessageTemplate replaces the built-in caption with the rendered
+// template.
+func (b *Bot) SetMessageTemplate(template *message.Template) {
+	b.template = template
+}
+
+// SetDryRun makes the bot log rendered messages instead of sending them.
+func (b *Bot) SetDryRun(enabled bool) {
+	b.dryRun = enabled
+}
+
+// SetTimeout changes how long a request to the Telegram Bot API may take.
+func (b *Bot) SetTimeout(timeout time.Duration) {
+	b.httpClient.SetTimeout(timeout)
+}
+
+// SetRetryPolicy retries messages that failed with a network error or a 5xx
+// response.
+func (b *Bot) SetRetryPolicy(policy customhttp.RetryPolicy) {
+	b.retry = policy
+}
+
+func (b *Bot) SendProduct(product models.Product) error {
+	return b.SendEvent(models.Event{Type: models.EventNew, Product: product})
+}
+
+func (b *Bot) SendEvent(event models.Event) error {
+	product := event.Product
+
+	heading := "🎉 New Product Alert!"
+	switch event.Type {
+	case models.EventUpcoming:
+		heading = "🔜 Upcoming Product"
+	case models.EventAvailable:
+		heading = "✅ Now Available"
+	case models.EventRemoved:
+		heading = "🚫 Product Removed"
+	case models.EventBackInStock:
+		heading = "🔁 New Variant / Back in Stock"
+	case models.EventPriceChange:
+		heading = "💲 Price Change"
+	case models.EventSale:
+		heading = "🏷️ Sale"
+	case models.EventUpdated:
+		heading = "✏️ Product Updated"
+	case models.EventInStock:
+		heading = "🚀 Now In Stock"
+	case models.EventSoldOut:
+		heading = "⛔ Sold Out"
+	case models.EventNewSubCategory:
+		heading = "🗂️ New Subcategory"
+	}
+
+	caption := fmt.Sprintf("%s\n\n%s\n%s%s", heading, event.Title(), priceLine(event), event.URL())
+
+	if b.template != nil {
+		rendered, err := b.template.Render(event)
+		if err != nil {
+			logger.Warning().Err(err).Str("id", product.ID).Msg("Failed to render message template, using the default caption")
+		} else {
+			caption = rendered
+		}
+	}
+
+	if b.dryRun {
+		logger.Info().
+			Str("photo", product.Thumbnail.URL).
+			Str("caption", caption).
+			Msg("Dry run, skipping Telegram message")
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("chat_id", b.chatID)
+	method := "sendPhoto"
+	if product.Thumbnail.URL != "" {
+		params.Set("photo", product.Thumbnail.URL)
+		params.Set("caption", caption)
+	} else {
+		// Subcategories have no image to attach the caption to
+		method = "sendMessage"
+		params.Set("text", caption)
+	}
+
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/%s?%s", b.token, method, params.Encode())
+
+	return b.retry.Do(b.Name(), func() error {
+		for attempt := 0; ; attempt++ {
+			retryAfter, err := b.send(endpoint)
+			if err != nil {
+				return err
+			}
+			if retryAfter == 0 {
+				return nil
+			}
+			if attempt >= maxRetries {
+				return fmt.Errorf("telegram rate limit persisted after %d retries", maxRetries)
+			}
+
+			// Rate limited, wait as long as Telegram asks and retry
+			time.Sleep(retryAfter)
+		}
+	})
+}
+
+// priceLine shows the old and new price of a price change or sale, and the
+// current price of other products.
+func priceLine(event models.Event) string {
+	switch {
+	case event.Type == models.EventNewSubCategory:
+		return ""
+	case (event.Type == models.EventPriceChange || event.Type == models.EventSale) && len(event.Variants) > 0:
+		variant := event.Variants[0]
+		oldPrice := models.FormatPrice(event.OldPrices[variant.ID], variant.DisplayPrice.Currency)
+		return fmt.Sprintf("Price: %s → %s\n", oldPrice, variant.Price())
+	case len(event.Product.Variants) > 0:
+		return fmt.Sprintf("Price: %s\n", event.Product.Variants[0].Price())
+	}
+	return ""
+}
+
+// send performs a single Bot API call. A non-zero duration is returned when
+// Telegram rate limited the request and asked us to retry later.
+func (b *Bot) send(endpoint string) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create telegram request: %w", err)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send telegram message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		// Proxies in front of the API answer outages with an HTML page
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return 0, &customhttp.StatusError{Service: "telegram", StatusCode: resp.StatusCode}
+		}
+		return 0, fmt.Errorf("failed to decode telegram response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if retryAfter <= 0 {
+			retryAfter = 5 * time.Second
+		}
+		return retryAfter, nil
+	}
+
+	if !result.OK {
+		return 0, &customhttp.StatusError{Service: "telegram", StatusCode: resp.StatusCode, Detail: result.Description}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,111 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	customhttp "all-unifi-monitor/internal/http"
+
+	http "github.com/saucesteals/fhttp"
+)
+
+// latestReleaseURL returns the newest published release of the project.
+const latestReleaseURL = "https://api.github.com/repos/authrequest/Unifi-Monitor/releases/latest"
+
+type release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// Update describes the latest release. Newer is only set when both it and
+// the running version are semantic versions and the release is newer.
+type Update struct {
+	Latest string
+	URL    string
+	Newer  bool
+}
+
+// CheckForUpdate asks GitHub for the latest release and compares its tag
+// with Version. Nothing is downloaded.
+func CheckForUpdate(ctx context.Context) (Update, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return Update{}, fmt.Errorf("failed to create release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := customhttp.NewClient().Do(req)
+	if err != nil {
+		return Update{}, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Update{}, fmt.Errorf("github returned status code: %d", resp.StatusCode)
+	}
+
+	var latest release
+	if err := json.NewDecoder(resp.Body).Decode(&latest); err != nil {
+		return Update{}, fmt.Errorf("failed to decode latest release: %w", err)
+	}
+
+	update := Update{Latest: latest.TagName, URL: latest.HTMLURL}
+	if current, ok := parseVersion(Version); ok {
+		if available, ok := parseVersion(latest.TagName); ok {
+			update.Newer = compareVersions(available, current) > 0
+		}
+	}
+	return update, nil
+}
+
+// semver holds the numeric parts of a version and whether it's a pre-release.
+type semver struct {
+	parts      [3]int
+	prerelease bool
+}
+
+// parseVersion accepts versions like v1.2.3, 1.2 or v1.2.3-rc.1.
+func parseVersion(value string) (semver, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	// Build metadata doesn't affect precedence
+	value, _, _ = strings.Cut(value, "+")
+	core, pre, hasPre := strings.Cut(value, "-")
+
+	fields := strings.Split(core, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return semver{}, false
+	}
+
+	var v semver
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v.parts[i] = n
+	}
+	v.prerelease = hasPre && pre != ""
+	return v, true
+}
+
+// compareVersions returns a positive number when a is newer than b, a
+// negative one when it's older and 0 when they're equal. A pre-release is
+// older than the release of the same version.
+func compareVersions(a, b semver) int {
+	for i := range a.parts {
+		if a.parts[i] != b.parts[i] {
+			return a.parts[i] - b.parts[i]
+		}
+	}
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case a.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,51 @@
+// Package version describes the running build. Version, Commit and Date are
+// set at build time, e.g.
+//
+//	go build -ldflags "-X all-unifi-monitor/internal/version.Version=v1.2.0 \
+//	  -X all-unifi-monitor/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X all-unifi-monitor/internal/version.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/monitor
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+func init() {
+	// Builds without -ldflags still know their commit when built from a
+	// git checkout
+	if Commit != "unknown" {
+		return
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			Commit = setting.Value
+		case "vcs.time":
+			if Date == "unknown" {
+				Date = setting.Value
+			}
+		}
+	}
+}
+
+// GoVersion is the Go version the binary was built with.
+func GoVersion() string {
+	return runtime.Version()
+}
+
+// String describes the build on a single line.
+func String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", Version, Commit, Date, GoVersion())
+}
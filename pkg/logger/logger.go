@@ -1,25 +1,105 @@
 package logger
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
 )
 
-var log = zerolog.New(
-	zerolog.ConsoleWriter{
+// log is swapped atomically so Configure can run while other goroutines log
+var log atomic.Pointer[zerolog.Logger]
+
+func init() {
+	logger := zerolog.New(consoleWriter()).Level(zerolog.TraceLevel).With().Timestamp().Caller().Logger()
+	log.Store(&logger)
+}
+
+func consoleWriter() io.Writer {
+	return zerolog.ConsoleWriter{
 		Out:        os.Stderr,
 		TimeFormat: time.RFC3339,
 		FormatLevel: func(i interface{}) string {
 			return fmt.Sprintf("[%-6s]", i)
 		},
-	},
-).Level(zerolog.TraceLevel).With().Timestamp().Caller().Logger()
+	}
+}
+
+// Configure replaces the global logger. format is either "console" or
+// "json". Caller information is only attached at debug and trace level since
+// it is noise in production logs.
+func Configure(level, format string) error {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	var out io.Writer
+	switch format {
+	case "", "console":
+		out = consoleWriter()
+	case "json":
+		out = os.Stderr
+	default:
+		return fmt.Errorf("invalid log format %q, expected console or json", format)
+	}
+
+	ctx := zerolog.New(out).Level(lvl).With().Timestamp()
+	if lvl <= zerolog.DebugLevel {
+		ctx = ctx.Caller()
+	}
+	logger := ctx.Logger()
+	log.Store(&logger)
+
+	return nil
+}
 
 // Expose logger methods
-func Info() *zerolog.Event    { return log.Info() }
-func Error() *zerolog.Event   { return log.Error() }
-func Fatal() *zerolog.Event   { return log.Fatal() }
-func Warning() *zerolog.Event { return log.Warn() }
+func Info() *zerolog.Event    { return log.Load().Info() }
+func Error() *zerolog.Event   { return log.Load().Error() }
+func Fatal() *zerolog.Event   { return log.Load().Fatal() }
+func Warning() *zerolog.Event { return log.Load().Warn() }
+
+// Logger attaches fields, such as correlation IDs, to every event logged
+// through it. The zero value logs like the package functions.
+type Logger struct {
+	fields []string
+}
+
+// With returns a Logger that adds key=value to every event.
+func With(key, value string) Logger {
+	return Logger{}.With(key, value)
+}
+
+// With returns a copy of l that also adds key=value to every event.
+func (l Logger) With(key, value string) Logger {
+	fields := make([]string, 0, len(l.fields)+2)
+	fields = append(fields, l.fields...)
+	return Logger{fields: append(fields, key, value)}
+}
+
+func (l Logger) Info() *zerolog.Event    { return l.attach(log.Load().Info()) }
+func (l Logger) Error() *zerolog.Event   { return l.attach(log.Load().Error()) }
+func (l Logger) Warning() *zerolog.Event { return l.attach(log.Load().Warn()) }
+
+func (l Logger) attach(event *zerolog.Event) *zerolog.Event {
+	for i := 0; i < len(l.fields); i += 2 {
+		event = event.Str(l.fields[i], l.fields[i+1])
+	}
+	return event
+}
+
+// NewID returns a short random ID used to correlate the log lines of a
+// sweep or request.
+func NewID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "00000000"
+	}
+	return hex.EncodeToString(b)
+}